@@ -3,19 +3,44 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/bytedance/ccs/internal/config"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var addCmd = &cobra.Command{
 	Use:   "add <name>",
 	Short: "Add a new profile",
-	Long:  `Add a new Claude Code configuration profile interactively.`,
-	Args:  cobra.ExactArgs(1),
-	Run:   runAdd,
+	Long: `Add a new Claude Code configuration profile interactively. With --isolated,
+the profile gets its own Claude Code config directory under ~/.ccs/homes, so
+history, credentials, MCP servers and project trust aren't shared with other
+profiles.
+
+With --env KEY=VALUE (repeatable) or --from-file, the profile is created without
+prompting and may hold any variable Claude Code reads, such as API_TIMEOUT_MS or
+HTTPS_PROXY. The file is JSON ({"env": {...}} or a flat object) or .env lines;
+"-" reads it from stdin. Values from --env override the file.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runAdd,
+}
+
+var (
+	addIsolated bool
+	addEnv      []string
+	addFromFile string
+)
+
+func init() {
+	addCmd.Flags().BoolVar(&addIsolated, "isolated", false, "give the profile its own Claude Code config directory")
+	addCmd.Flags().StringArrayVar(&addEnv, "env", nil, "set a variable (KEY=VALUE, repeatable)")
+	addCmd.Flags().StringVar(&addFromFile, "from-file", "", "read variables from a JSON or .env file (- for stdin)")
 }
 
 func runAdd(cmd *cobra.Command, args []string) {
@@ -28,45 +53,239 @@ func runAdd(cmd *cobra.Command, args []string) {
 	}
 
 	profile := config.NewProfile()
-	reader := bufio.NewReader(os.Stdin)
+	profile.Isolated = addIsolated
 
-	// Prompt for each config value
-	inputs := []struct {
-		key   string
-		prompt string
-	}{
-		{config.EnvAuthToken, "ANTHROPIC_AUTH_TOKEN"},
-		{config.EnvBaseURL, "ANTHROPIC_BASE_URL"},
-		{config.EnvDefaultHaikuModel, "ANTHROPIC_DEFAULT_HAIKU_MODEL"},
-		{config.EnvDefaultOpusModel, "ANTHROPIC_DEFAULT_OPUS_MODEL"},
-		{config.EnvDefaultSonnetModel, "ANTHROPIC_DEFAULT_SONNET_MODEL"},
-		{config.EnvModel, "ANTHROPIC_MODEL"},
-	}
-
-	for _, input := range inputs {
-		fmt.Printf("%s: ", input.prompt)
-		value, _ := reader.ReadString('\n')
-		value = strings.TrimSpace(value)
-		if value != "" {
-			profile.SetEnv(input.key, value)
+	if addFromFile != "" || len(addEnv) > 0 {
+		env, err := readEnvFlags(addFromFile, addEnv)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
+		profile.Env = env
+	} else {
+		promptProfile(profile)
 	}
 
 	// Check if profile is empty (all fields empty)
-	if len(profile.Env) == 0 {
+	if len(profile.Env) == 0 && !profile.Isolated {
 		fmt.Fprintln(os.Stderr, "Error: Profile cannot be empty. Provide at least one configuration value.")
 		os.Exit(1)
 	}
 
+	// Reload under the lock so profiles changed by other ccs processes
+	// while prompting aren't lost
+	release := lockState()
+	defer release()
+
+	store, err = config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading profiles: %v\n", err)
+		os.Exit(1)
+	}
+
 	if err := store.AddProfile(name, profile); err != nil {
 		fmt.Fprintf(os.Stderr, "Error adding profile: %v\n", err)
 		os.Exit(1)
 	}
 
-	if err := store.Save(); err != nil {
+	if err := config.Record("add "+name, store.Save); err != nil {
 		fmt.Fprintf(os.Stderr, "Error saving profiles: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Printf("Profile '%s' added successfully.\n", name)
 }
+
+// promptProfile asks for each standard profile value on stdin. Secrets
+// aren't echoed when stdin is a terminal and invalid values are asked for
+// again. ANTHROPIC_MODEL is asked first among the models, and each later
+// model field offers the models entered so far, defaulting to the first.
+func promptProfile(profile *config.Profile) {
+	in := &prompter{
+		reader:   bufio.NewReader(os.Stdin),
+		terminal: term.IsTerminal(int(os.Stdin.Fd())),
+	}
+
+	var keys []string
+	for _, key := range config.EnvKeys {
+		if config.IsModelKey(key) && !slices.Contains(keys, config.EnvModel) {
+			keys = append(keys, config.EnvModel)
+		}
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	var models []string
+	for _, key := range keys {
+		var choices []string
+		if config.IsModelKey(key) {
+			choices = models
+		}
+
+		value := in.askValid(key, choices)
+		if value == "" {
+			continue
+		}
+		profile.SetEnv(key, value)
+		if config.IsModelKey(key) && !slices.Contains(models, value) {
+			models = append(models, value)
+		}
+	}
+}
+
+// prompter reads answers from stdin
+type prompter struct {
+	reader   *bufio.Reader
+	terminal bool
+}
+
+// askValid prompts for key until it gets a valid value, which is returned
+// normalized. Choices are listed by number when there are several, and
+// answering a number takes that choice. An empty answer takes the first
+// choice, and "-" leaves the key unset when there is one. It exits if stdin
+// ends on an invalid value.
+func (p *prompter) askValid(key string, choices []string) string {
+	def := ""
+	if len(choices) > 0 {
+		def = choices[0]
+	}
+	for {
+		if len(choices) > 1 {
+			for i, choice := range choices {
+				fmt.Printf("  %d) %s\n", i+1, choice)
+			}
+		}
+		prompt := key
+		if len(choices) > 1 {
+			prompt += fmt.Sprintf(" [%s, 1-%d to choose, - for none]", def, len(choices))
+		} else if def != "" {
+			prompt += " [" + def + ", - for none]"
+		}
+
+		var value string
+		var eof bool
+		if config.IsSecretKey(key) {
+			value, eof = p.readSecret(prompt + ": ")
+		} else {
+			value, eof = p.readLine(prompt + ": ")
+		}
+
+		switch value {
+		case "":
+			value = def
+		case "-":
+			if def != "" {
+				value = ""
+			}
+		default:
+			if n, err := strconv.Atoi(value); err == nil && n >= 1 && n <= len(choices) {
+				value = choices[n-1]
+			}
+		}
+		if value == "" {
+			return ""
+		}
+
+		normalized, err := config.ValidateEnvValue(key, value)
+		if err == nil && key == config.EnvBaseURL && config.IsInsecureURL(normalized) {
+			err = p.confirmInsecure()
+		}
+		if err == nil {
+			return normalized
+		}
+
+		fmt.Fprintf(os.Stderr, "Invalid %s: %v\n", key, err)
+		if eof {
+			os.Exit(1)
+		}
+	}
+}
+
+const insecureURLWarning = "This URL is not https, so the token would be sent unencrypted."
+
+// confirmInsecure asks whether to use a base URL that would send the token
+// unencrypted. Piped input holds only the values, so there it is accepted
+// with a warning.
+func (p *prompter) confirmInsecure() error {
+	if !p.terminal {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", insecureURLWarning)
+		return nil
+	}
+	answer, _ := p.readLine(insecureURLWarning + " Use it anyway? [y/N]: ")
+	if !strings.EqualFold(answer, "y") && !strings.EqualFold(answer, "yes") {
+		return fmt.Errorf("not using an unencrypted URL")
+	}
+	return nil
+}
+
+// readLine prints prompt and reads one trimmed line, reporting whether
+// stdin has ended
+func (p *prompter) readLine(prompt string) (string, bool) {
+	fmt.Print(prompt)
+	line, err := p.reader.ReadString('\n')
+	return strings.TrimSpace(line), err != nil
+}
+
+// readSecret is readLine without echo when stdin is a terminal. Only the
+// length is shown afterwards, so a bad paste can be spotted.
+func (p *prompter) readSecret(prompt string) (string, bool) {
+	if !p.terminal {
+		return p.readLine(prompt)
+	}
+
+	fmt.Print(prompt)
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	value := strings.TrimSpace(string(data))
+	if value != "" {
+		fmt.Printf("(%d characters)", utf8.RuneCountInString(value))
+	}
+	fmt.Println()
+	return value, err != nil
+}
+
+// readEnvFlags builds env values from a --from-file path and --env
+// assignments, which take precedence
+func readEnvFlags(path string, assignments []string) (map[string]string, error) {
+	env := make(map[string]string)
+	if path != "" {
+		var data []byte
+		var err error
+		if path == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if env, err = config.ParseProfileData(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for _, arg := range assignments {
+		key, value, err := config.ParseEnvAssignment(arg)
+		if err != nil {
+			return nil, err
+		}
+		env[key] = value
+	}
+	return env, validateEnv(env)
+}
+
+// validateEnv checks and normalizes env values given as flags or
+// arguments, warning about a base URL that isn't https
+func validateEnv(env map[string]string) error {
+	for key, value := range env {
+		normalized, err := config.ValidateEnvValue(key, value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if key == config.EnvBaseURL && config.IsInsecureURL(normalized) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", insecureURLWarning)
+		}
+		env[key] = normalized
+	}
+	return nil
+}
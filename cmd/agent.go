@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Cache the store passphrase in a background agent",
+	Long: `Start a background agent, in the spirit of ssh-agent, that keeps the key of an
+encrypted profile store in memory so commands don't prompt for the passphrase
+every time. The agent listens on a socket in ~/.ccs that only you can open,
+and forgets the key after agentTimeoutMinutes in ~/.ccs/config.json (default
+15, 0 for never) or when you run 'ccs agent lock'.`,
+	Args: cobra.NoArgs,
+	Run:  runAgentStart,
+}
+
+var agentStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the agent in the background",
+	Args:  cobra.NoArgs,
+	Run:   runAgentStart,
+}
+
+var agentStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Wipe the cached key and stop the agent",
+	Args:  cobra.NoArgs,
+	Run:   runAgentStop,
+}
+
+var agentLockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Wipe the cached key",
+	Args:  cobra.NoArgs,
+	Run:   runAgentLock,
+}
+
+var agentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the agent is running",
+	Args:  cobra.NoArgs,
+	Run:   runAgentStatus,
+}
+
+var agentServeCmd = &cobra.Command{
+	Use:    "serve",
+	Short:  "Run the agent in the foreground",
+	Args:   cobra.NoArgs,
+	Hidden: true,
+	Run:    runAgentServe,
+}
+
+var agentTimeout time.Duration
+
+func init() {
+	for _, c := range []*cobra.Command{agentCmd, agentStartCmd, agentServeCmd} {
+		c.Flags().DurationVar(&agentTimeout, "timeout", -1, "how long to keep the key, e.g. 30m (0 for never)")
+	}
+
+	agentCmd.AddCommand(agentStartCmd)
+	agentCmd.AddCommand(agentStopCmd)
+	agentCmd.AddCommand(agentLockCmd)
+	agentCmd.AddCommand(agentStatusCmd)
+	agentCmd.AddCommand(agentServeCmd)
+}
+
+func runAgentStart(cmd *cobra.Command, args []string) {
+	if status, err := config.GetAgentStatus(); err == nil {
+		fmt.Printf("ccs agent is already running (pid %d).\n", status.PID)
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting agent: %v\n", err)
+		os.Exit(1)
+	}
+
+	child := exec.Command(exe, "agent", "serve", "--timeout", resolveAgentTimeout().String())
+	child.SysProcAttr = detachedProcAttr()
+	if err := child.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting agent: %v\n", err)
+		os.Exit(1)
+	}
+	_ = child.Process.Release()
+
+	// Wait for the socket so the next command can use the agent right away
+	for i := 0; i < 50; i++ {
+		if status, err := config.GetAgentStatus(); err == nil {
+			fmt.Printf("ccs agent started (pid %d).\n", status.PID)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	fmt.Fprintln(os.Stderr, "Error: ccs agent did not start; run 'ccs agent serve' to see why.")
+	os.Exit(1)
+}
+
+func runAgentServe(cmd *cobra.Command, args []string) {
+	if err := config.ServeAgent(resolveAgentTimeout()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runAgentStop(cmd *cobra.Command, args []string) {
+	if err := config.AgentStop(); err != nil {
+		exitAgentError(err)
+	}
+	fmt.Println("ccs agent stopped.")
+}
+
+func runAgentLock(cmd *cobra.Command, args []string) {
+	if err := config.AgentLock(); err != nil {
+		exitAgentError(err)
+	}
+	fmt.Println("Cached key wiped.")
+}
+
+func runAgentStatus(cmd *cobra.Command, args []string) {
+	status, err := config.GetAgentStatus()
+	if err != nil {
+		exitAgentError(err)
+	}
+
+	timeout := "never"
+	if status.Timeout > 0 {
+		timeout = status.Timeout.String()
+	}
+	fmt.Printf("ccs agent is running (pid %d), holding %d keys, timeout %s.\n", status.PID, status.Keys, timeout)
+}
+
+// resolveAgentTimeout returns the --timeout flag if given, else the
+// configured agent timeout
+func resolveAgentTimeout() time.Duration {
+	if agentTimeout >= 0 {
+		return agentTimeout
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return time.Duration(cfg.AgentTimeoutMinutes) * time.Minute
+}
+
+// exitAgentError reports an error talking to the agent and exits
+func exitAgentError(err error) {
+	if errors.Is(err, config.ErrAgentNotRunning) {
+		fmt.Fprintln(os.Stderr, "ccs agent is not running; start it with 'ccs agent'.")
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	os.Exit(1)
+}
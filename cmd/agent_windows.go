@@ -0,0 +1,15 @@
+//go:build windows
+
+package cmd
+
+import "syscall"
+
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts the agent without a console so it outlives the
+// terminal that started it
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+	}
+}
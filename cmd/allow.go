@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var allowCmd = &cobra.Command{
+	Use:   "allow [path]",
+	Short: "Trust a .ccs-profile file",
+	Long: `Allow the ` + config.ProjectProfileFile + ` file at path, or the nearest one above the current
+directory, to select a profile through 'ccs hook'. A file can run commands
+through cmd: references, so it must be allowed again after every change.
+Use --revoke to stop trusting it.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runAllow,
+}
+
+var allowRevoke bool
+
+func init() {
+	allowCmd.Flags().BoolVar(&allowRevoke, "revoke", false, "stop trusting the file")
+}
+
+func runAllow(cmd *cobra.Command, args []string) {
+	var pp *config.ProjectProfile
+	var err error
+	if len(args) > 0 {
+		path := args[0]
+		if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+			path = path + string(os.PathSeparator) + config.ProjectProfileFile
+		}
+		pp, err = config.ReadProjectProfile(path)
+	} else {
+		cwd, _ := os.Getwd()
+		pp, err = config.FindProjectProfile(cwd)
+		if err == nil && pp == nil {
+			err = fmt.Errorf("no %s found in this directory or its parents", config.ProjectProfileFile)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	release := lockState()
+	defer release()
+
+	if allowRevoke {
+		if err := pp.Revoke(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("No longer trusting %s.\n", pp.Path)
+		return
+	}
+
+	if err := pp.Allow(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Allowed %s (profile '%s').\n", pp.Path, pp.Name)
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Manage backups",
+	Long:  `List, inspect, compare and restore the backups ccs takes before every change to settings.json, ~/.claude.json and profiles.json.`,
+}
+
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backups",
+	Long:  `List backups, newest first, with the profile that was active and the files saved in each.`,
+	Args:  cobra.NoArgs,
+	Run:   runBackupList,
+}
+
+var backupShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a backup",
+	Long:  `Print a settings.json backup with tokens masked.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   runBackupShow,
+}
+
+var backupDiffCmd = &cobra.Command{
+	Use:   "diff <id>",
+	Short: "Compare a backup with the live settings",
+	Long:  `Show what changed between a settings.json backup and the live settings.json.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   runBackupDiff,
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Restore a backup",
+	Long: `Put back the files saved in a backup, or only the ones named with --only
+(settings, claude, profiles, ownership). The current files are backed up
+first, so a restore can itself be undone.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runBackupRestore,
+}
+
+var backupScrubCmd = &cobra.Command{
+	Use:   "scrub",
+	Short: "Remove secrets from existing backups and the undo journal",
+	Long: `Rewrite existing backups and undo journal entries so tokens and other secret
+env values are replaced with placeholders, as new ones already are. Restoring
+a scrubbed backup or undoing refills the secrets from the profile store.`,
+	Args: cobra.NoArgs,
+	Run:  runBackupScrub,
+}
+
+var backupRestoreOnly []string
+
+func init() {
+	backupRestoreCmd.Flags().StringSliceVar(&backupRestoreOnly, "only", nil, "restore only these files ("+strings.Join(config.BackupKinds, ", ")+", "+config.BackupProject+", "+config.BackupLocal+")")
+
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupShowCmd)
+	backupCmd.AddCommand(backupDiffCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupScrubCmd)
+}
+
+func runBackupList(cmd *cobra.Command, args []string) {
+	backups, err := config.ListBackups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(backups) == 0 {
+		fmt.Println("No backups found.")
+		return
+	}
+
+	fmt.Println("Backups:")
+	for _, backup := range backups {
+		profile := backup.Profile
+		if profile == "" {
+			profile = "-"
+		}
+		fmt.Printf("  %s  %s  %-12s  %s\n", backup.ID, backup.Created.Format("2006-01-02 15:04:05"),
+			profile, strings.Join(backup.Kinds(), ","))
+	}
+}
+
+func runBackupShow(cmd *cobra.Command, args []string) {
+	settings := loadBackupSettings(args[0])
+
+	for key, value := range settings.Env {
+		settings.Env[key] = config.MaskValue(key, value)
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(data))
+}
+
+func runBackupDiff(cmd *cobra.Command, args []string) {
+	old := loadBackupSettings(args[0])
+
+	live, err := config.GetCurrentClaudeSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	changes := config.DiffSettings(old, live)
+	if len(changes) == 0 {
+		fmt.Println("No differences.")
+		return
+	}
+
+	fmt.Printf("Changes from backup %s to live settings.json:\n", args[0])
+	for _, change := range changes {
+		key := strings.TrimPrefix(change.Key, "env.")
+		switch change.Kind {
+		case config.ChangeAdded:
+			fmt.Printf("  + %s: %s\n", change.Key, config.MaskValue(key, change.New))
+		case config.ChangeRemoved:
+			fmt.Printf("  - %s: %s\n", change.Key, config.MaskValue(key, change.Old))
+		case config.ChangeModified:
+			fmt.Printf("  ~ %s: %s -> %s\n", change.Key,
+				config.MaskValue(key, change.Old), config.MaskValue(key, change.New))
+		}
+	}
+}
+
+func runBackupRestore(cmd *cobra.Command, args []string) {
+	id := args[0]
+
+	release := lockState()
+	defer release()
+
+	backup, err := config.GetBackup(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	kinds := backupRestoreOnly
+	if len(kinds) == 0 {
+		kinds = backup.Kinds()
+	}
+
+	err = config.Record("backup restore "+id, func() error {
+		return config.RestoreBackup(id, kinds...)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error restoring backup: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Restored %s from backup '%s'.\n", strings.Join(kinds, ", "), id)
+	fmt.Println("The previous files were backed up; use 'ccs backup list' to find them.")
+}
+
+func runBackupScrub(cmd *cobra.Command, args []string) {
+	release := lockState()
+	defer release()
+
+	scrubbed, err := config.ScrubBackups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error scrubbing backups: %v\n", err)
+		os.Exit(1)
+	}
+
+	if scrubbed == 0 {
+		fmt.Println("No secrets found in backups or the journal.")
+		return
+	}
+	fmt.Printf("Removed secrets from %d backup and journal files.\n", scrubbed)
+}
+
+// loadBackupSettings reads the settings stored in backup id, exiting on error
+func loadBackupSettings(id string) *config.ClaudeSettings {
+	backup, err := config.GetBackup(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	settings, err := backup.Settings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return settings
+}
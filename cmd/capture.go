@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var captureCmd = &cobra.Command{
+	Use:   "capture <name>",
+	Short: "Create a profile from the current Claude settings",
+	Long: `Create a profile from the env values in the current settings.json, or only the
+known profile keys with --only-known, and make it the active profile. The values
+stay where they are: ccs takes them over as if it had applied them, so switching
+to another profile later removes them.
+
+With --from-env the profile is built from the current environment instead,
+taking ANTHROPIC_* variables and documented Claude Code settings such as
+HTTPS_PROXY, and with --from-backup from a backup id (see 'ccs backup list') or
+a settings file. Such a profile is applied as with 'ccs use' right away.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runCapture,
+}
+
+var (
+	captureOnlyKnown  bool
+	captureFromEnv    bool
+	captureFromBackup string
+)
+
+func init() {
+	captureCmd.Flags().BoolVar(&captureOnlyKnown, "only-known", false, "capture only the standard profile keys")
+	captureCmd.Flags().BoolVar(&captureFromEnv, "from-env", false, "capture from the current environment")
+	captureCmd.Flags().StringVar(&captureFromBackup, "from-backup", "", "capture from a backup id or settings file")
+	captureCmd.MarkFlagsMutuallyExclusive("from-env", "from-backup")
+}
+
+func runCapture(cmd *cobra.Command, args []string) {
+	name := args[0]
+
+	release := lockState()
+	defer release()
+
+	store := loadStore()
+
+	var profile *config.Profile
+	switch {
+	case captureFromEnv:
+		profile = config.CaptureEnviron(os.Environ(), captureOnlyKnown)
+	case captureFromBackup != "":
+		var err error
+		profile, err = config.CaptureBackup(captureFromBackup, captureOnlyKnown)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading backup: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		settings, err := config.GetCurrentClaudeSettings()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading settings: %v\n", err)
+			os.Exit(1)
+		}
+		profile = config.CaptureSettings(settings, captureOnlyKnown)
+	}
+
+	if len(profile.Env) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: nothing to capture.")
+		os.Exit(1)
+	}
+
+	err := config.Record("capture "+name, func() error {
+		if !captureFromEnv && captureFromBackup == "" {
+			return store.AdoptCaptured(name, profile)
+		}
+		if err := store.AddProfile(name, profile); err != nil {
+			return err
+		}
+		_, err := store.Switch(name)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error capturing profile: %v\n", err)
+		os.Exit(1)
+	}
+
+	keys := make([]string, 0, len(profile.Env))
+	for key := range profile.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("Profile '%s' captured and active:\n", name)
+	for _, key := range keys {
+		fmt.Printf("  %s = %s\n", key, config.MaskValue(key, profile.Env[key]))
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check file permissions",
+	Long: `Report ccs and Claude Code files that may contain tokens and are readable
+by other users. Use --fix to restrict them to the owner.`,
+	Args: cobra.NoArgs,
+	Run:  runCheck,
+}
+
+var checkFix bool
+
+func init() {
+	checkCmd.Flags().BoolVar(&checkFix, "fix", false, "remove group and other access")
+}
+
+func runCheck(cmd *cobra.Command, args []string) {
+	issues := config.CheckPermissions()
+	if len(issues) == 0 {
+		fmt.Println("All files are private to their owner.")
+		return
+	}
+
+	for _, issue := range issues {
+		fmt.Printf("  %s  %s -> %s\n", issue.Mode, issue.Path, issue.Want)
+	}
+
+	if !checkFix {
+		fmt.Printf("%d files or directories are accessible to other users. Run 'ccs check --fix' to restrict them.\n", len(issues))
+		os.Exit(1)
+	}
+
+	if err := config.FixPermissions(issues); err != nil {
+		fmt.Fprintf(os.Stderr, "Error fixing permissions: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restricted %d files or directories to their owner.\n", len(issues))
+}
+
+// warnPermissions prints a one-line warning if token-bearing files are
+// accessible to other users. It is skipped for check itself and for the
+// commands other programs run on every prompt or request: the shell hook,
+// apiKeyHelper's token and env.
+func warnPermissions(cmd *cobra.Command, args []string) {
+	switch cmd {
+	case checkCmd, hookCmd, tokenCmd, envCmd:
+		return
+	}
+	if issues := config.CheckPermissions(); len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d files that may contain tokens are readable by other users; run 'ccs check --fix'.\n", len(issues))
+	}
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env [name]",
+	Short: "Print a profile's environment variables",
+	Long: `Print the env vars of a profile (the active one by default) for use outside
+Claude Code's settings.json, with secret references resolved:
+
+  eval "$(ccs env work)"                                   # sh, bash, zsh
+  ccs env work --format fish | source                      # fish
+  ccs env work --format powershell | Invoke-Expression     # PowerShell
+  ccs env work --format github-actions >> "$GITHUB_ENV"    # GitHub Actions
+
+` + config.SessionEnv + ` is set to the profile name. --unset prints the commands that remove
+the variables of the profile named in ` + config.SessionEnv + ` (or of the given one).`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runEnv,
+}
+
+var (
+	envFormat string
+	envUnset  bool
+	envRedact bool
+)
+
+func init() {
+	envCmd.Flags().StringVar(&envFormat, "format", config.FormatSh, "output format ("+strings.Join(config.EnvFormats, ", ")+")")
+	envCmd.Flags().BoolVar(&envUnset, "unset", false, "print commands that remove the previously exported variables")
+	envCmd.Flags().BoolVar(&envRedact, "redact", false, "mask tokens and secretPatterns keys and leave secret references unresolved")
+}
+
+func runEnv(cmd *cobra.Command, args []string) {
+	store := loadStore()
+
+	if envUnset {
+		runEnvUnset(store, args)
+		return
+	}
+
+	name := store.Current
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: no active profile; name one.")
+		os.Exit(1)
+	}
+
+	profile, err := store.GetProfile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	env := make(map[string]string, len(profile.Env)+2)
+	if envRedact {
+		isSecret := config.SecretKeyMatcher()
+		for key, value := range profile.Env {
+			if isSecret(key) && !config.IsSecretRef(value) && !config.IsRedacted(value) {
+				value = "***"
+			}
+			env[key] = value
+		}
+	} else {
+		if env, err = profile.ResolvedEnv(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if profile.Isolated {
+		home := store.ClaudeConfigDir(name)
+		if !envRedact {
+			release := lockState()
+			home, err = store.SyncIsolatedHome(name)
+			release()
+			if home == "" {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		env[config.ClaudeConfigDirEnv] = home
+	} else if settings, err := config.GetCurrentClaudeSettings(); err == nil && !envRedact {
+		if keys := config.OverriddenKeys(env, settings); len(keys) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: ~/.claude/settings.json sets %s, which Claude Code will use instead of these values.\n",
+				strings.Join(keys, ", "))
+		}
+	}
+	env[config.SessionEnv] = name
+
+	if err := config.FormatEnv(os.Stdout, envFormat, env); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runEnvUnset prints the commands that remove the variables of the
+// profile exported before, or of the named one
+func runEnvUnset(store *config.Store, args []string) {
+	name := os.Getenv(config.SessionEnv)
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		name = store.Current
+	}
+
+	var keys []string
+	if profile, err := store.GetProfile(name); err == nil {
+		for key := range profile.Env {
+			keys = append(keys, key)
+		}
+		if profile.Isolated {
+			keys = append(keys, config.ClaudeConfigDirEnv)
+		}
+	}
+	keys = append(keys, config.SessionEnv)
+
+	if err := config.FormatUnset(os.Stdout, envFormat, sortedUnique(keys)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// sortedUnique returns keys sorted with duplicates removed
+func sortedUnique(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	var out []string
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <name> -- <command> [args...]",
+	Short: "Run a command with a profile's environment",
+	Long: `Run a command with the profile's env vars (secret references resolved) and
+` + config.SessionEnv + ` set in its environment, without touching ~/.claude; isolated
+profiles also get CLAUDE_CONFIG_DIR pointing at their own home. Useful
+for running Claude Code against different providers in different terminals:
+
+  ccs exec work -- claude`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  runExec,
+}
+
+func runExec(cmd *cobra.Command, args []string) {
+	if dash := cmd.ArgsLenAtDash(); dash != 1 {
+		fmt.Fprintln(os.Stderr, "Error: usage: ccs exec <name> -- <command> [args...]")
+		os.Exit(1)
+	}
+
+	os.Exit(runWithProfile(args[0], args[1:]))
+}
+
+// runWithProfile runs argv with the environment of profile name and
+// returns its exit code, exiting on errors before it starts
+func runWithProfile(name string, argv []string) int {
+	store := loadStore()
+	profile, err := store.GetProfile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	env, err := profile.ResolvedEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if profile.Isolated {
+		// Point Claude Code at the profile's own home, brought up to date
+		release := lockState()
+		home, err := store.SyncIsolatedHome(name)
+		release()
+		if home == "" {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		env[config.ClaudeConfigDirEnv] = home
+	} else if settings, err := config.GetCurrentClaudeSettings(); err == nil {
+		if keys := config.OverriddenKeys(env, settings); len(keys) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: ~/.claude/settings.json sets %s, which Claude Code will use instead of profile '%s'.\n",
+				strings.Join(keys, ", "), name)
+		}
+	}
+
+	child := exec.Command(argv[0], argv[1:]...)
+	child.Env = config.SessionEnviron(os.Environ(), name, env)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	// The child shares the terminal, so let it handle Ctrl-C itself
+	signal.Ignore(os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+
+	err = child.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [key]",
+	Short: "Show where each Claude Code setting comes from",
+	Long: `Show the value Claude Code will use for each profile key when started in the
+current directory, which file it comes from and which profile set it. Layers are
+read in Claude Code's order of precedence: managed-settings.json, the project's
+.claude/settings.local.json and .claude/settings.json, the user settings.json,
+and finally the environment Claude Code is started with. Values that a higher
+layer overrides are listed below the winning one. Tokens are masked.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runExplain,
+}
+
+func runExplain(cmd *cobra.Command, args []string) {
+	store := loadStore()
+
+	keys := config.EnvKeys
+	if len(args) > 0 {
+		keys = args
+	}
+
+	explanations, errs := store.Explain(".", keys)
+
+	paths := make([]string, 0, len(errs))
+	for path := range errs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(os.Stderr, "Warning: skipped %s: %v\n", path, errs[path])
+	}
+
+	for _, e := range explanations {
+		effective := e.Effective()
+		if effective == nil {
+			fmt.Printf("%s: not set\n", e.Key)
+			continue
+		}
+		fmt.Printf("%s = %s\n", e.Key, config.MaskValue(e.Key, effective.Value))
+		fmt.Printf("    from %s\n", describeLayer(*effective, ""))
+		for _, v := range e.Values[1:] {
+			fmt.Printf("    overrides %s\n", describeLayer(v, config.MaskValue(e.Key, v.Value)))
+		}
+	}
+}
+
+// describeLayer names the layer a value comes from, the value if given
+// and the profile that set it
+func describeLayer(v config.LayerValue, value string) string {
+	desc := v.Layer
+	if v.Source != "" {
+		desc += " (" + v.Source + ")"
+	}
+	if value != "" {
+		desc += " = " + value
+	}
+	if v.Profile != "" {
+		desc += ", set by profile '" + v.Profile + "'"
+	}
+	return desc
+}
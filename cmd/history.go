@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show the operation journal",
+	Long:  `List recent state-changing operations, most recent first. 'ccs undo' reverts them in this order.`,
+	Args:  cobra.NoArgs,
+	Run:   runHistory,
+}
+
+func runHistory(cmd *cobra.Command, args []string) {
+	entries, err := config.History()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No operations recorded.")
+		return
+	}
+
+	fmt.Println("History:")
+	for _, entry := range entries {
+		fmt.Printf("  %s  %-24s  %s\n", entry.Time.Format("2006-01-02 15:04:05"),
+			entry.Command, strings.Join(entry.Kinds(), ","))
+	}
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// Variables the hook keeps in the shell to know what it exported
+const (
+	hookStateEnv = "CCS_HOOK_STATE"
+	hookKeysEnv  = "CCS_HOOK_KEYS"
+)
+
+// hookScripts are the prompt hooks printed by ccs hook
+var hookScripts = map[string]string{
+	"bash": `_ccs_hook() {
+  local previous_exit_status=$?
+  eval "$(ccs hook --export bash)"
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_ccs_hook;"* ]]; then
+  PROMPT_COMMAND="_ccs_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`,
+	"zsh": `_ccs_hook() {
+  eval "$(ccs hook --export zsh)"
+}
+typeset -ag precmd_functions chpwd_functions
+if (( ! ${precmd_functions[(I)_ccs_hook]} )); then
+  precmd_functions=(_ccs_hook $precmd_functions)
+fi
+if (( ! ${chpwd_functions[(I)_ccs_hook]} )); then
+  chpwd_functions=(_ccs_hook $chpwd_functions)
+fi
+`,
+	"fish": `function _ccs_hook --on-variable PWD --on-event fish_prompt
+    ccs hook --export fish | source
+end
+`,
+}
+
+// hookFormats maps each supported shell to its ccs env format
+var hookFormats = map[string]string{
+	"bash": config.FormatSh,
+	"zsh":  config.FormatSh,
+	"fish": config.FormatFish,
+}
+
+var hookCmd = &cobra.Command{
+	Use:   "hook <bash|zsh|fish>",
+	Short: "Print a shell hook for directory-based profiles",
+	Long: `Print a hook that, before each prompt, looks for a ` + config.ProjectProfileFile + ` file in the
+current directory or its parents and exports the env of the profile it names,
+unsetting it again when you leave. Add it to your shell's startup file:
+
+  eval "$(ccs hook bash)"     # ~/.bashrc
+  eval "$(ccs hook zsh)"      # ~/.zshrc
+  ccs hook fish | source      # ~/.config/fish/config.fish
+
+A ` + config.ProjectProfileFile + ` file only takes effect once trusted with 'ccs allow', and
+again after every change to it.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runHook,
+}
+
+var hookExport bool
+
+func init() {
+	hookCmd.Flags().BoolVar(&hookExport, "export", false, "print the commands to run at this prompt")
+	_ = hookCmd.Flags().MarkHidden("export")
+}
+
+func runHook(cmd *cobra.Command, args []string) {
+	shell := args[0]
+	format, ok := hookFormats[shell]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unsupported shell '%s' (want bash, zsh or fish)\n", shell)
+		os.Exit(1)
+	}
+
+	if !hookExport {
+		fmt.Print(hookScripts[shell])
+		return
+	}
+
+	// Errors here would be printed at every prompt; keep them short
+	if err := runHookExport(format); err != nil {
+		fmt.Fprintf(os.Stderr, "ccs: %v\n", err)
+	}
+}
+
+// runHookExport prints the commands that bring the shell in line with the
+// .ccs-profile for the current directory. Nothing is printed while the
+// file, its trust and the profile it names are unchanged. The state is
+// only recorded once the export succeeds, so a failure is retried at the
+// next prompt.
+func runHookExport(format string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	pp, err := config.FindProjectProfile(cwd)
+	if err != nil {
+		return err
+	}
+
+	state := ""
+	if pp != nil {
+		state = fmt.Sprintf("%s|%s|%t|%s", pp.Path, pp.Hash, pp.Allowed(), config.ProfileHash(pp.Name))
+	}
+	if state == os.Getenv(hookStateEnv) {
+		return nil
+	}
+
+	// Unset what the previous file exported
+	var unset []string
+	if keys := os.Getenv(hookKeysEnv); keys != "" {
+		unset = strings.Split(keys, ",")
+	}
+	unset = append(unset, hookKeysEnv)
+	if state == "" {
+		unset = append(unset, hookStateEnv)
+	}
+	if err := config.FormatUnset(os.Stdout, format, unset); err != nil {
+		return err
+	}
+
+	if pp == nil {
+		return nil
+	}
+
+	if !pp.Allowed() {
+		// Recorded so the message is shown once; 'ccs allow' changes the state
+		if err := config.FormatEnv(os.Stdout, format, map[string]string{hookStateEnv: state}); err != nil {
+			return err
+		}
+		return fmt.Errorf("%s is not allowed; run 'ccs allow' to trust it", pp.Path)
+	}
+
+	// The hook runs inside eval at every prompt; never ask for a passphrase
+	config.PassphrasePrompt = func(string) (string, error) {
+		return "", fmt.Errorf("profiles.json is encrypted; start 'ccs agent' or set %s", config.PassphraseEnv)
+	}
+
+	store, err := config.Load()
+	if err != nil {
+		return err
+	}
+	exported, err := store.ProjectEnv(pp)
+	if err != nil {
+		return fmt.Errorf("%s: %w", pp.Path, err)
+	}
+	if store.ClaudeConfigDir(pp.Name) != "" {
+		release := lockState()
+		_, err = store.SyncIsolatedHome(pp.Name)
+		release()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ccs: %v\n", err)
+		}
+	}
+	exported[config.SessionEnv] = pp.Name
+
+	env := map[string]string{hookStateEnv: state}
+	keys := make([]string, 0, len(exported))
+	for key, value := range exported {
+		env[key] = value
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env[hookKeysEnv] = strings.Join(keys, ",")
+	if err := config.FormatEnv(os.Stdout, format, env); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "ccs: using profile '%s' from %s\n", pp.Name, pp.Path)
+	return nil
+}
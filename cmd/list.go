@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/bytedance/ccs/internal/config"
 	"github.com/spf13/cobra"
@@ -11,8 +12,16 @@ import (
 var listCmd = &cobra.Command{
 	Use:   "ls",
 	Short: "List all profiles",
-	Long:  `List all Claude Code configuration profiles.`,
-	Run:   runList,
+	Long: `List all Claude Code configuration profiles, flagging env: and file: secret
+references that currently fail to resolve. With --check, cmd: references are
+run too, without stdin and with a 10 second timeout.`,
+	Run: runList,
+}
+
+var listCheck bool
+
+func init() {
+	listCmd.Flags().BoolVar(&listCheck, "check", false, "also run cmd: references and flag those that fail")
 }
 
 func runList(cmd *cobra.Command, args []string) {
@@ -28,12 +37,48 @@ func runList(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// Scopes each profile is active at for the current directory
+	active := store.ActiveScopes(".")
+	activeAt := make(map[string][]string)
+	for _, scope := range config.Scopes {
+		if name, ok := active[scope]; ok {
+			activeAt[name] = append(activeAt[name], scope)
+		}
+	}
+
+	// Flag the user-scope profile if settings.json no longer matches it
+	drifted := false
+	if report, err := store.CheckDrift(); err == nil && report != nil {
+		drifted = report.Drifted()
+	}
+
 	fmt.Println("Profiles:")
 	for _, name := range profiles {
-		if name == store.Current {
-			fmt.Printf("  %s (active)\n", name)
-		} else {
-			fmt.Printf("  %s\n", name)
+		profile, _ := store.GetProfile(name)
+
+		label := name
+		if profile.Isolated {
+			label += " [isolated]"
+		}
+		scopes := activeAt[name]
+		userDrifted := drifted && len(scopes) > 0 && scopes[0] == config.ScopeUser
+		switch {
+		case len(scopes) == 0:
+			fmt.Printf("  %s\n", label)
+		case len(scopes) == 1 && scopes[0] == config.ScopeUser && userDrifted:
+			fmt.Printf("  %s (active, drifted)\n", label)
+		case len(scopes) == 1 && scopes[0] == config.ScopeUser:
+			fmt.Printf("  %s (active)\n", label)
+		default:
+			if userDrifted {
+				scopes[0] += " (drifted)"
+			}
+			fmt.Printf("  %s (active: %s)\n", label, strings.Join(scopes, ", "))
+		}
+
+		keys, failed := profile.CheckRefs(listCheck)
+		for _, key := range keys {
+			fmt.Printf("    ! %s = %s: %v\n", key, profile.Env[key], failed[key])
 		}
 	}
 }
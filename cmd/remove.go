@@ -19,28 +19,41 @@ var removeCmd = &cobra.Command{
 func runRemove(cmd *cobra.Command, args []string) {
 	name := args[0]
 
+	release := lockState()
+	defer release()
+
 	store, err := config.Load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading profiles: %v\n", err)
 		os.Exit(1)
 	}
 
-	// If removing the active profile, clear its settings first
-	if store.Current == name {
-		if profile, err := store.GetProfile(name); err == nil {
-			_ = profile.ClearFromClaude() // Ignore errors, continue anyway
+	removedConfigDir := store.ClaudeConfigDir(name)
+
+	err = config.Record("rm "+name, func() error {
+		// If removing the active profile, clear its settings first
+		if store.Current == name {
+			if profile, err := store.GetProfile(name); err == nil {
+				_ = profile.ClearFromClaude() // Ignore errors, continue anyway
+			}
 		}
-	}
 
-	if err := store.RemoveProfile(name); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
+		if err := store.RemoveProfile(name); err != nil {
+			return err
+		}
 
-	if err := store.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving profiles: %v\n", err)
+		if err := store.Save(); err != nil {
+			return fmt.Errorf("failed to save profiles: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Printf("Profile '%s' removed successfully.\n", name)
+	if removedConfigDir != "" {
+		fmt.Printf("Its isolated Claude Code home was kept at %s; delete it if you no longer need its history.\n", removedConfigDir)
+	}
 }
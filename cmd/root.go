@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/bytedance/ccs/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -11,6 +13,8 @@ var rootCmd = &cobra.Command{
 	Use:   "ccs",
 	Short: "CCS - Claude Code Switcher",
 	Long:  `A CLI tool to manage and switch between Claude Code configuration profiles.`,
+
+	PersistentPreRun: warnPermissions,
 }
 
 func Execute() {
@@ -20,10 +24,41 @@ func Execute() {
 	}
 }
 
+// lockState takes the ccs state lock for a load–modify–save cycle, exiting
+// with a clear message if another ccs process holds it for too long
+func lockState() func() {
+	release, err := config.Lock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, config.ErrLockTimeout) {
+			fmt.Fprintln(os.Stderr, "Another ccs command or 'ccs ui' is busy; try again once it finishes.")
+		}
+		os.Exit(1)
+	}
+	return release
+}
+
 func init() {
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(useCmd)
 	rootCmd.AddCommand(removeCmd)
 	rootCmd.AddCommand(uiCmd)
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(storeCmd)
+	rootCmd.AddCommand(agentCmd)
+	rootCmd.AddCommand(tokenCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(shellCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(allowCmd)
+	rootCmd.AddCommand(explainCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(captureCmd)
+	rootCmd.AddCommand(setCmd)
+	rootCmd.AddCommand(unsetCmd)
 }
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var setCmd = &cobra.Command{
+	Use:   "set <name> KEY=VALUE...",
+	Short: "Set variables in a profile",
+	Long: `Set one or more variables in an existing profile. Any variable Claude Code
+reads can be stored, not only the standard keys. If the profile is active, the
+change is applied to settings.json right away.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  runSet,
+}
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset <name> KEY...",
+	Short: "Remove variables from a profile",
+	Long: `Remove one or more variables from an existing profile. If the profile is
+active, they are removed from settings.json too, or put back to the values ccs
+found there before it applied them.`,
+	Args: cobra.MinimumNArgs(2),
+	Run:  runUnset,
+}
+
+func runSet(cmd *cobra.Command, args []string) {
+	name := args[0]
+	set := make(map[string]string)
+	for _, arg := range args[1:] {
+		key, value, err := config.ParseEnvAssignment(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		set[key] = value
+	}
+	if err := validateEnv(set); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	updateProfile("set "+name+" "+strings.Join(sortedUnique(keysOf(set)), " "), name, set, nil)
+}
+
+func runUnset(cmd *cobra.Command, args []string) {
+	name := args[0]
+	updateProfile("unset "+strings.Join(args, " "), name, nil, args[1:])
+}
+
+// updateProfile applies a set or unset to profile name as one journaled
+// command, exiting on error
+func updateProfile(command, name string, set map[string]string, unset []string) {
+	release := lockState()
+	defer release()
+
+	store := loadStore()
+
+	var applied bool
+	err := config.Record(command, func() error {
+		var err error
+		applied, err = store.UpdateProfile(name, set, unset)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error updating profile: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Profile '%s' updated.\n", name)
+	if applied {
+		fmt.Println("Applied to settings.json; restart Claude Code to pick up the change.")
+	} else if store.Current == name || isActiveInScope(store, name) {
+		fmt.Printf("Run 'ccs use %s' to apply the change.\n", name)
+	}
+}
+
+// isActiveInScope reports whether name is active at project or local
+// scope for the current directory
+func isActiveInScope(store *config.Store, name string) bool {
+	for scope, active := range store.ActiveScopes(".") {
+		if scope != config.ScopeUser && active == name {
+			return true
+		}
+	}
+	return false
+}
+
+// keysOf returns the keys of m
+func keysOf(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var shellCmd = &cobra.Command{
+	Use:   "shell <name>",
+	Short: "Start a shell with a profile's environment",
+	Long: `Start $SHELL with the profile's env vars and ` + config.SessionEnv + ` set, without
+touching ~/.claude. Exit the shell to return.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runShell,
+}
+
+func runShell(cmd *cobra.Command, args []string) {
+	name := args[0]
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		if runtime.GOOS == "windows" {
+			shell = os.Getenv("COMSPEC")
+		} else {
+			shell = "/bin/sh"
+		}
+	}
+
+	if os.Getenv(config.SessionEnv) != "" {
+		fmt.Fprintf(os.Stderr, "Warning: already in a ccs shell for profile '%s'.\n", os.Getenv(config.SessionEnv))
+	}
+
+	fmt.Fprintf(os.Stderr, "Starting %s with profile '%s'; exit to return.\n", shell, name)
+	os.Exit(runWithProfile(name, []string{shell}))
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Check the active profile against settings.json",
+	Long: `Compare the active profile with the settings.json it was applied to and report
+each key as matching, modified (set to another value), missing, or foreign (a
+profile key the active profile doesn't define, such as one left behind by
+another profile). Exits with status 1 if anything has drifted, so it can be used
+in scripts; 'ccs use <name>' applies the profile again. Tokens are masked.`,
+	Args: cobra.NoArgs,
+	Run:  runStatus,
+}
+
+func runStatus(cmd *cobra.Command, args []string) {
+	store := loadStore()
+
+	report, err := store.CheckDrift()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error checking settings: %v\n", err)
+		os.Exit(1)
+	}
+	if report == nil {
+		fmt.Println("No active profile.")
+		return
+	}
+
+	fmt.Printf("Profile '%s' in %s:\n", report.Profile, report.Path)
+	for _, k := range report.Keys {
+		switch k.State {
+		case config.KeyModified:
+			fmt.Printf("  %-9s %s = %s (profile: %s)\n", k.State, k.Key, config.MaskValue(k.Key, k.Have), config.MaskValue(k.Key, k.Want))
+		case config.KeyForeign:
+			fmt.Printf("  %-9s %s = %s\n", k.State, k.Key, config.MaskValue(k.Key, k.Have))
+		default:
+			fmt.Printf("  %-9s %s\n", k.State, k.Key)
+		}
+	}
+
+	if report.Drifted() {
+		fmt.Printf("Settings have drifted from the profile; run 'ccs use %s' to apply it again.\n", report.Profile)
+		os.Exit(1)
+	}
+	fmt.Println("Settings match the profile.")
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// newPassphraseEnv supplies the new passphrase for encrypt and rekey when
+// running non-interactively
+const newPassphraseEnv = "CCS_NEW_PASSPHRASE"
+
+var storeCmd = &cobra.Command{
+	Use:   "store",
+	Short: "Manage profile store encryption",
+	Long: `Encrypt the env values in ~/.ccs/profiles.json with a passphrase, change the
+passphrase, or go back to plaintext. An encrypted store is unlocked with a
+prompt, or from ` + config.PassphraseEnv + ` when set. These commands are not recorded
+for 'ccs undo'.`,
+}
+
+var storeEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the profile store",
+	Args:  cobra.NoArgs,
+	Run:   runStoreEncrypt,
+}
+
+var storeDecryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt the profile store",
+	Args:  cobra.NoArgs,
+	Run:   runStoreDecrypt,
+}
+
+var storeRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Change the store passphrase",
+	Args:  cobra.NoArgs,
+	Run:   runStoreRekey,
+}
+
+func init() {
+	storeCmd.AddCommand(storeEncryptCmd)
+	storeCmd.AddCommand(storeDecryptCmd)
+	storeCmd.AddCommand(storeRekeyCmd)
+}
+
+func runStoreEncrypt(cmd *cobra.Command, args []string) {
+	release := lockState()
+	defer release()
+
+	store := loadStore()
+	if store.Encrypted() {
+		fmt.Fprintln(os.Stderr, "Error: profiles are already encrypted; use 'ccs store rekey' to change the passphrase.")
+		os.Exit(1)
+	}
+
+	if err := store.SetPassphrase(readNewPassphrase()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encrypting profiles: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Profiles encrypted, along with their copies in backups and the undo journal.")
+}
+
+func runStoreDecrypt(cmd *cobra.Command, args []string) {
+	release := lockState()
+	defer release()
+
+	store := loadStore()
+	if err := store.RemovePassphrase(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decrypting profiles: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Profiles decrypted.")
+}
+
+func runStoreRekey(cmd *cobra.Command, args []string) {
+	release := lockState()
+	defer release()
+
+	store := loadStore()
+	if !store.Encrypted() {
+		fmt.Fprintln(os.Stderr, "Error: profiles are not encrypted; use 'ccs store encrypt' first.")
+		os.Exit(1)
+	}
+
+	if err := store.SetPassphrase(readNewPassphrase()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error changing passphrase: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Passphrase changed.")
+}
+
+// loadStore loads the profile store, exiting on error
+func loadStore() *config.Store {
+	store, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading profiles: %v\n", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+// readNewPassphrase reads a new passphrase from the environment, or
+// prompts for it twice, exiting on error
+func readNewPassphrase() string {
+	if passphrase, ok := os.LookupEnv(newPassphraseEnv); ok {
+		return passphrase
+	}
+
+	passphrase, err := config.PassphrasePrompt("New passphrase: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (set %s to run non-interactively)\n", err, newPassphraseEnv)
+		os.Exit(1)
+	}
+	confirm, err := config.PassphrasePrompt("Repeat passphrase: ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if passphrase != confirm {
+		fmt.Fprintln(os.Stderr, "Error: passphrases do not match.")
+		os.Exit(1)
+	}
+	return passphrase
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token [name]",
+	Short: "Print a profile's auth token",
+	Long: `Print the resolved auth token of the named profile, or of the active one, on
+stdout. With applyMode set to "apiKeyHelper" in ~/.ccs/config.json, 'ccs use'
+points Claude Code's apiKeyHelper at this command instead of writing the token
+into settings.json.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runToken,
+}
+
+func runToken(cmd *cobra.Command, args []string) {
+	store := loadStore()
+
+	name := store.Current
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: no active profile.")
+		os.Exit(1)
+	}
+
+	profile, err := store.GetProfile(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	token, err := profile.Token()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: profile '%s': %v\n", name, err)
+		os.Exit(1)
+	}
+	fmt.Println(token)
+}
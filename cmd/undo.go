@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bytedance/ccs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo the last change",
+	Long: `Revert the most recent state-changing operation (use, rm, add, backup restore,
+or the same actions in the TUI). Run it again to walk further back; see
+'ccs history' for what would be undone.`,
+	Args: cobra.NoArgs,
+	Run:  runUndo,
+}
+
+var undoForce bool
+
+func init() {
+	undoCmd.Flags().BoolVar(&undoForce, "force", false, "undo even if the files were changed since")
+}
+
+func runUndo(cmd *cobra.Command, args []string) {
+	release := lockState()
+	defer release()
+
+	entry, err := config.Undo(undoForce)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Undid '%s' (%s).\n", entry.Command, strings.Join(entry.Kinds(), ", "))
+}
@@ -11,57 +11,83 @@ import (
 var useCmd = &cobra.Command{
 	Use:   "use <name>",
 	Short: "Switch to a profile",
-	Long:  `Switch to the specified Claude Code configuration profile.`,
-	Args:  cobra.ExactArgs(1),
-	Run:   runUse,
+	Long: `Switch to the specified Claude Code configuration profile.
+
+--scope picks the settings file the profile is written to: user (the default,
+~/.claude/settings.json), project (<project>/.claude/settings.json) or local
+(<project>/.claude/settings.local.json, which is git-ignored). The project is
+the enclosing git work tree, or the current directory outside of git. The
+project settings file is meant to be committed, so the token is left out at
+project scope; provide it at user or local scope.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runUse,
+}
+
+var useScope string
+
+func init() {
+	useCmd.Flags().StringVar(&useScope, "scope", config.ScopeUser, "settings file to apply to: user, project or local")
 }
 
 func runUse(cmd *cobra.Command, args []string) {
 	name := args[0]
 
+	release := lockState()
+	defer release()
+
 	store, err := config.Load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading profiles: %v\n", err)
 		os.Exit(1)
 	}
 
-	profile, err := store.GetProfile(name)
+	target, err := config.ResolveScope(useScope, ".")
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// If there was a previous active profile, clear its settings first
-	if store.Current != "" && store.Current != name {
-		if oldProfile, err := store.GetProfile(store.Current); err == nil {
-			_ = oldProfile.ClearFromClaude() // Ignore errors, continue anyway
-		}
+	command := "use " + name
+	if target.Scope != config.ScopeUser {
+		command += " --scope " + target.Scope
 	}
 
-	// Apply the new profile
-	if err := profile.ApplyToClaude(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error applying profile: %v\n", err)
+	// Clear the old profile, apply the new one and save the store as one
+	// transaction; on failure everything is rolled back
+	var result *config.SwitchResult
+	err = config.RecordScope(command, target, func() error {
+		var err error
+		result, err = store.SwitchScope(name, target)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error switching profile: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Set hasCompletedOnboarding to skip Claude Code's first-time setup
-	if changed, err := config.SetHasCompletedOnboarding(); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to set onboarding flag: %v\n", err)
-	} else if changed {
-		fmt.Println("Onboarding flag set: first-time setup will be skipped.")
-	}
-
-	// Update store current
-	if err := store.SetCurrent(name); err != nil {
-		fmt.Fprintf(os.Stderr, "Error updating active profile: %v\n", err)
-		os.Exit(1)
+	if target.Scope != config.ScopeUser {
+		fmt.Printf("Switched %s scope to profile '%s' in %s.\n", target.Scope, name, target.Path)
+		if result.TokenOmitted {
+			fmt.Println("The token was left out of the shared project settings; provide it at user or local scope,")
+			fmt.Printf("e.g. 'ccs use %s --scope local'.\n", name)
+		}
+		return
 	}
 
-	if err := store.Save(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error saving profiles: %v\n", err)
-		os.Exit(1)
+	if result.OnboardingErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to set onboarding flag: %v\n", result.OnboardingErr)
+	} else if result.OnboardingSet {
+		fmt.Println("Onboarding flag set: first-time setup will be skipped.")
 	}
 
 	fmt.Printf("Switched to profile '%s'.\n", name)
+	if result.ConfigDir != "" {
+		if result.LinkErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", result.LinkErr)
+		}
+		fmt.Printf("This profile is isolated; start Claude Code with CLAUDE_CONFIG_DIR=%s\n", result.ConfigDir)
+		fmt.Printf("or run 'ccs exec %s -- claude'.\n", name)
+		return
+	}
 	fmt.Println("Restart your terminal or Claude Code to apply changes.")
 }
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// agentDialTimeout bounds how long a client waits for the agent
+const agentDialTimeout = 500 * time.Millisecond
+
+// ErrAgentNotRunning is returned when no agent is listening on the socket
+var ErrAgentNotRunning = errors.New("ccs agent is not running")
+
+// agentRequest is one request sent to the agent as a line of JSON
+type agentRequest struct {
+	Op   string `json:"op"`
+	Salt string `json:"salt,omitempty"`
+	Key  []byte `json:"key,omitempty"`
+}
+
+// agentResponse is the agent's reply to a request
+type agentResponse struct {
+	Key     []byte        `json:"key,omitempty"`
+	Keys    int           `json:"keys"`
+	Timeout time.Duration `json:"timeout"`
+	PID     int           `json:"pid"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// AgentStatus describes a running agent
+type AgentStatus struct {
+	PID     int
+	Keys    int
+	Timeout time.Duration
+}
+
+// GetAgentSocketPath returns the path of the agent's Unix socket
+func GetAgentSocketPath() string {
+	return filepath.Join(getCCSDir(), "agent.sock")
+}
+
+// agentCall sends req to the agent and returns its response
+func agentCall(req agentRequest) (*agentResponse, error) {
+	conn, err := net.DialTimeout("unix", GetAgentSocketPath(), agentDialTimeout)
+	if err != nil {
+		return nil, ErrAgentNotRunning
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	var resp agentResponse
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return &resp, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// agentGetKey asks the agent for the cached store key with the given salt
+func agentGetKey(salt string) ([]byte, bool) {
+	resp, err := agentCall(agentRequest{Op: "get", Salt: salt})
+	if err != nil || len(resp.Key) == 0 {
+		return nil, false
+	}
+	return resp.Key, true
+}
+
+// agentAddKey hands an unlocked store key to the agent, if one is running
+func agentAddKey(salt string, key []byte) {
+	_, _ = agentCall(agentRequest{Op: "add", Salt: salt, Key: key})
+}
+
+// AgentLock makes the agent wipe every cached key
+func AgentLock() error {
+	_, err := agentCall(agentRequest{Op: "lock"})
+	return err
+}
+
+// AgentStop makes the agent wipe its keys and exit
+func AgentStop() error {
+	_, err := agentCall(agentRequest{Op: "stop"})
+	return err
+}
+
+// GetAgentStatus reports on the running agent
+func GetAgentStatus() (*AgentStatus, error) {
+	resp, err := agentCall(agentRequest{Op: "status"})
+	if err != nil {
+		return nil, err
+	}
+	return &AgentStatus{PID: resp.PID, Keys: resp.Keys, Timeout: resp.Timeout}, nil
+}
+
+// agentKey is a store key held by the agent until it expires
+type agentKey struct {
+	key   []byte
+	timer *time.Timer
+}
+
+// agentServer holds unlocked store keys in memory
+type agentServer struct {
+	mu      sync.Mutex
+	keys    map[string]*agentKey
+	timeout time.Duration
+	done    chan struct{}
+}
+
+// ServeAgent listens on the agent socket and caches store keys, each for
+// timeout after it was added (0 keeps keys until locked). It returns when
+// the agent is stopped.
+func ServeAgent(timeout time.Duration) error {
+	path := GetAgentSocketPath()
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	// Remove a socket left behind by an agent that didn't shut down cleanly
+	if _, err := agentCall(agentRequest{Op: "status"}); err == nil {
+		return fmt.Errorf("ccs agent is already running")
+	}
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer os.Remove(path)
+	if err := os.Chmod(path, privateFileMode); err != nil {
+		listener.Close()
+		return err
+	}
+
+	server := &agentServer{
+		keys:    make(map[string]*agentKey),
+		timeout: timeout,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		<-server.done
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-server.done:
+				return nil
+			default:
+				return err
+			}
+		}
+		go server.handle(conn)
+	}
+}
+
+// handle answers one request
+func (s *agentServer) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var req agentRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	resp := agentResponse{Timeout: s.timeout, PID: os.Getpid()}
+	switch req.Op {
+	case "get":
+		if k, ok := s.keys[req.Salt]; ok {
+			resp.Key = k.key
+		}
+	case "add":
+		s.removeLocked(req.Salt)
+		k := &agentKey{key: req.Key}
+		if s.timeout > 0 {
+			salt := req.Salt
+			k.timer = time.AfterFunc(s.timeout, func() {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+				s.removeLocked(salt)
+			})
+		}
+		s.keys[req.Salt] = k
+	case "lock":
+		s.wipeLocked()
+	case "stop":
+		s.wipeLocked()
+		defer close(s.done)
+	case "status":
+	default:
+		resp.Error = fmt.Sprintf("unknown request '%s'", req.Op)
+	}
+	resp.Keys = len(s.keys)
+	s.mu.Unlock()
+
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// removeLocked wipes and forgets the key for salt; s.mu must be held
+func (s *agentServer) removeLocked(salt string) {
+	k, ok := s.keys[salt]
+	if !ok {
+		return
+	}
+	if k.timer != nil {
+		k.timer.Stop()
+	}
+	for i := range k.key {
+		k.key[i] = 0
+	}
+	delete(s.keys, salt)
+}
+
+// wipeLocked wipes every key; s.mu must be held
+func (s *agentServer) wipeLocked() {
+	for salt := range s.keys {
+		s.removeLocked(salt)
+	}
+}
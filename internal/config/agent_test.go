@@ -0,0 +1,89 @@
+//go:build unix
+
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// startTestAgent runs an agent in-process and stops it when the test ends
+func startTestAgent(t *testing.T, timeout time.Duration) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- ServeAgent(timeout) }()
+
+	for i := 0; ; i++ {
+		if _, err := GetAgentStatus(); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("agent did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Cleanup(func() {
+		_ = AgentStop()
+		<-done
+	})
+}
+
+func TestLoadUsesAgentKey(t *testing.T) {
+	setupClaudeHome(t, "")
+	startTestAgent(t, 0)
+
+	store := newSwitchStore(t)
+	if err := store.SetPassphrase("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the process cache or a passphrase, the key must come from the agent
+	clear(unlockedKeys)
+	prompt := PassphrasePrompt
+	t.Cleanup(func() { PassphrasePrompt = prompt })
+	PassphrasePrompt = func(string) (string, error) {
+		t.Fatal("prompted despite a running agent")
+		return "", nil
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := loaded.Profiles["second"].GetAuthToken(); token != "sk-second" {
+		t.Errorf("token not decrypted: %q", token)
+	}
+
+	if err := AgentLock(); err != nil {
+		t.Fatal(err)
+	}
+	clear(unlockedKeys)
+	PassphrasePrompt = func(string) (string, error) { return "", errNoTerminal }
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to fail once the agent is locked")
+	}
+}
+
+func TestAgentKeyExpires(t *testing.T) {
+	setupClaudeHome(t, "")
+	startTestAgent(t, 50*time.Millisecond)
+
+	agentAddKey("salt", []byte("key"))
+	if _, ok := agentGetKey("salt"); !ok {
+		t.Fatal("key not cached")
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, ok := agentGetKey("salt"); ok {
+		t.Error("key still cached after timeout")
+	}
+}
+
+func TestAgentNotRunning(t *testing.T) {
+	setupClaudeHome(t, "")
+	if err := AgentLock(); !errors.Is(err, ErrAgentNotRunning) {
+		t.Errorf("expected ErrAgentNotRunning, got %v", err)
+	}
+}
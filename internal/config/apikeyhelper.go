@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Apply modes, set with applyMode in ~/.ccs/config.json
+const (
+	// ApplyModeEnv writes every profile value, token included, into the
+	// env of settings.json
+	ApplyModeEnv = "env"
+	// ApplyModeAPIKeyHelper writes the token as an apiKeyHelper that runs
+	// 'ccs token', so settings.json and its backups never hold the secret
+	ApplyModeAPIKeyHelper = "apiKeyHelper"
+)
+
+// EnvAPIKey is the other env key Claude Code reads a secret from
+const EnvAPIKey = "ANTHROPIC_API_KEY"
+
+// settingAPIKeyHelper is the settings.json key for the token command
+const settingAPIKeyHelper = "apiKeyHelper"
+
+// tokenKeys are the env keys provided by apiKeyHelper instead of env in
+// apiKeyHelper mode, in order of preference for 'ccs token'
+var tokenKeys = []string{EnvAuthToken, EnvAPIKey}
+
+// isTokenKey reports whether key is one of tokenKeys
+func isTokenKey(key string) bool {
+	for _, k := range tokenKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyHelperCommand returns the apiKeyHelper that prints the token of
+// profile name, or of the active profile if name is empty
+func apiKeyHelperCommand(name string) string {
+	if name == "" {
+		return "ccs token"
+	}
+	return "ccs token " + name
+}
+
+// loadApplyMode returns the configured apply mode
+func loadApplyMode() (string, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	switch cfg.ApplyMode {
+	case "", ApplyModeEnv:
+		return ApplyModeEnv, nil
+	case ApplyModeAPIKeyHelper:
+		return ApplyModeAPIKeyHelper, nil
+	}
+	return "", fmt.Errorf("unknown applyMode '%s' in config.json (want '%s' or '%s')", cfg.ApplyMode, ApplyModeEnv, ApplyModeAPIKeyHelper)
+}
+
+// hasToken reports whether the profile sets any of tokenKeys
+func (p *Profile) hasToken() bool {
+	for _, key := range tokenKeys {
+		if _, ok := p.GetEnv(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Token returns the profile's resolved auth token, or its API key if it
+// has no auth token
+func (p *Profile) Token() (string, error) {
+	for _, key := range tokenKeys {
+		if value, ok := p.GetEnv(key); ok {
+			token, err := ResolveValue(value)
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve %s: %w", key, err)
+			}
+			return token, nil
+		}
+	}
+	return "", fmt.Errorf("profile has no %s", EnvAuthToken)
+}
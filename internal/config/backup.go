@@ -0,0 +1,648 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the timestamp format used for backup ids. Ids sort
+// in creation order.
+const backupTimeFormat = "20060102-150405.000000000"
+
+// legacyBackupTimeFormat is the one-second format of older backup ids
+const legacyBackupTimeFormat = "20060102-150405"
+
+// backupNamePattern matches <kind>-<id>.json backup file names
+var backupNamePattern = regexp.MustCompile(`^([a-z]+)-(\d{8}-\d{6}(?:\.\d{9})?)\.json$`)
+
+// Backup file kinds. Every file taken in one backup shares the same id.
+const (
+	BackupSettings  = "settings"
+	BackupClaude    = "claude"
+	BackupProfiles  = "profiles"
+	BackupOwnership = "ownership"
+
+	// Project and local settings files live in a project, so their paths
+	// are recorded in the backup's metadata
+	BackupProject = "project"
+	BackupLocal   = "local"
+
+	// backupMetaKind names the metadata file written for each backup id
+	backupMetaKind = "meta"
+)
+
+// BackupKinds lists the state files a backup can contain
+var BackupKinds = []string{
+	BackupSettings,
+	BackupClaude,
+	BackupProfiles,
+	BackupOwnership,
+}
+
+// backupSources returns the live path of a backup kind
+var backupSources = map[string]func() string{
+	BackupSettings:  getClaudeConfigPath,
+	BackupClaude:    getClaudeJSONPath,
+	BackupProfiles:  getProfilesPath,
+	BackupOwnership: getOwnershipPath,
+}
+
+// scopedBackupKinds lists the kinds whose live path depends on the project
+var scopedBackupKinds = []string{
+	BackupProject,
+	BackupLocal,
+}
+
+// allBackupKinds returns every kind a backup can contain
+func allBackupKinds() []string {
+	return append(append([]string{}, BackupKinds...), scopedBackupKinds...)
+}
+
+// livePath returns the live path of a backup kind, looking scoped kinds up
+// in paths
+func livePath(kind string, paths map[string]string) string {
+	if source, ok := backupSources[kind]; ok {
+		return source()
+	}
+	return paths[kind]
+}
+
+// liveFileMode returns the permissions to write the live file at path of a
+// backup kind with: files ccs owns are private, while Claude Code's own
+// files keep whatever mode they already have. A new project settings.json
+// is meant to be committed, so it is created readable by others.
+func liveFileMode(kind, path string) os.FileMode {
+	switch kind {
+	case BackupSettings, BackupClaude, BackupLocal:
+		return existingMode(path, privateFileMode)
+	case BackupProject:
+		return existingMode(path, 0644)
+	}
+	return privateFileMode
+}
+
+// Backup is a set of state files copied before ccs changed them
+type Backup struct {
+	ID      string
+	Created time.Time
+	// Profile is the profile that was active when the backup was taken
+	Profile string
+	// Files maps each backed-up kind to its backup file
+	Files map[string]string
+	// Paths maps project and local kinds to the file they were taken from
+	Paths map[string]string
+}
+
+// backupMeta is stored for each backup as meta-<id>.json
+type backupMeta struct {
+	Profile string            `json:"profile"`
+	Paths   map[string]string `json:"paths,omitempty"`
+}
+
+// getBackupDir returns the backup directory (~/.ccs/backups)
+func getBackupDir() string {
+	return filepath.Join(getCCSDir(), "backups")
+}
+
+// backupClaudeSettings creates a backup of the current Claude settings
+// and the ownership ledger that goes with them
+func backupClaudeSettings() error {
+	return backupFiles(BackupSettings, BackupOwnership)
+}
+
+// backupClaudeJSON creates a backup of the current ~/.claude.json
+func backupClaudeJSON() error {
+	return backupFiles(BackupClaude)
+}
+
+// backupProfiles creates a backup of the current profiles.json
+func backupProfiles() error {
+	return backupFiles(BackupProfiles)
+}
+
+// backupFiles copies the live files of the given kinds into ~/.ccs/backups
+// under one new backup id, recording the active profile alongside them.
+// Secret env values are replaced with placeholders.
+func backupFiles(kinds ...string) error {
+	return backupFilesAt(nil, kinds...)
+}
+
+// backupFilesAt is backupFiles for a set that may include project or local
+// settings files, whose live paths are given in paths
+func backupFilesAt(paths map[string]string, kinds ...string) error {
+	isSecret := SecretKeyMatcher()
+	sources := make(map[string][]byte)
+	used := make(map[string]string)
+	for _, kind := range kinds {
+		path := livePath(kind, paths)
+		if _, global := backupSources[kind]; !global {
+			used[kind] = path
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // No file to backup
+			}
+			return err
+		}
+
+		// Keep secrets out of backups; a file that can't be parsed is
+		// backed up as it is
+		if redacted, _, err := redactBackupData(kind, data, isSecret); err == nil {
+			data = redacted
+		}
+		sources[kind] = data
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	// Create backup directory in ~/.ccs
+	backupDir := getBackupDir()
+	if err := os.MkdirAll(backupDir, privateDirMode); err != nil {
+		return err
+	}
+
+	// Record which profile was active; best effort
+	meta := backupMeta{Profile: loadCurrentName()}
+	if len(used) > 0 {
+		meta.Paths = used
+	}
+	metaData, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	// Claim a new, unique id by creating its metadata file
+	id, err := createBackupFile(backupDir, backupMetaKind, metaData)
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range kinds {
+		data, ok := sources[kind]
+		if !ok {
+			continue
+		}
+		if err := writeNewFile(backupFilePath(backupDir, kind, id), data); err != nil {
+			return err
+		}
+	}
+
+	// Clean up old backups according to the retention policy
+	retention := DefaultConfig().Backups
+	if cfg, err := LoadConfig(); err == nil {
+		retention = cfg.Backups
+	}
+	pruneBackups(backupDir, retention, time.Now())
+
+	return nil
+}
+
+// backupFilePath returns the path of a backup file
+func backupFilePath(backupDir, kind, id string) string {
+	return filepath.Join(backupDir, kind+"-"+id+".json")
+}
+
+// createBackupFile writes data to <kind>-<id>.json in dir, where id is
+// derived from the current time and bumped until no existing file has it
+func createBackupFile(backupDir, kind string, data []byte) (string, error) {
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		id := now.Add(time.Duration(i)).Format(backupTimeFormat)
+		err := writeNewFile(backupFilePath(backupDir, kind, id), data)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return id, nil
+	}
+	return "", fmt.Errorf("failed to find a free backup name in %s", backupDir)
+}
+
+// parseBackupID returns the time a backup id was created, accepting both
+// current and legacy ids
+func parseBackupID(id string) (time.Time, error) {
+	layout := backupTimeFormat
+	if !strings.Contains(id, ".") {
+		layout = legacyBackupTimeFormat
+	}
+	return time.ParseInLocation(layout, id, time.Local)
+}
+
+// writeNewFile writes data to path, failing if path already exists
+func writeNewFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, privateFileMode)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// backupFileInfo is a backup file found in the backup directory
+type backupFileInfo struct {
+	kind string
+	id   string
+	path string
+	size int64
+}
+
+// pruneBackups deletes backups that fall outside the retention policy.
+// Each kind of file is counted separately, so backups of profiles.json
+// alone don't push out older settings.json backups. Files sharing an id are
+// removed together once every file in it has expired, and the newest backup
+// of each kind is always kept. Only files following the ccs backup naming
+// scheme are considered.
+func pruneBackups(backupDir string, retention BackupRetention, now time.Time) {
+	byID := make(map[string][]backupFileInfo)
+	byKind := make(map[string][]backupFileInfo)
+	for _, file := range listBackupFiles(backupDir) {
+		byID[file.id] = append(byID[file.id], file)
+		if file.kind != backupMetaKind {
+			byKind[file.kind] = append(byKind[file.kind], file)
+		}
+	}
+
+	expired := make(map[string]bool)
+	for _, files := range byKind {
+		for _, file := range expiredBackupFiles(files, retention, now) {
+			expired[file.path] = true
+		}
+	}
+
+	for _, files := range byID {
+		if !allExpired(files, expired) {
+			continue
+		}
+		for _, file := range files {
+			os.Remove(file.path)
+		}
+	}
+}
+
+// allExpired reports whether files holds at least one backup file and
+// every one of them has expired
+func allExpired(files []backupFileInfo, expired map[string]bool) bool {
+	found := false
+	for _, file := range files {
+		if file.kind == backupMetaKind {
+			continue
+		}
+		if !expired[file.path] {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// expiredBackupFiles returns the files of one kind that fall outside the
+// retention policy, never including the newest
+func expiredBackupFiles(files []backupFileInfo, retention BackupRetention, now time.Time) []backupFileInfo {
+	// Ids sort in creation order; walk newest first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].id > files[j].id
+	})
+
+	var expired []backupFileInfo
+	var total int64
+	maxSize := int64(retention.MaxTotalSizeMB) << 20
+	for i, file := range files {
+		total += file.size
+		if i == 0 {
+			continue
+		}
+
+		old := false
+		if retention.MaxCount > 0 && i >= retention.MaxCount {
+			old = true
+		}
+		if retention.MaxAgeDays > 0 {
+			created, err := parseBackupID(file.id)
+			if err == nil && now.Sub(created) > time.Duration(retention.MaxAgeDays)*24*time.Hour {
+				old = true
+			}
+		}
+		if maxSize > 0 && total > maxSize {
+			old = true
+		}
+		if old {
+			expired = append(expired, file)
+		}
+	}
+	return expired
+}
+
+// listBackupFiles returns every file in backupDir that follows the ccs
+// backup naming scheme
+func listBackupFiles(backupDir string) []backupFileInfo {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return nil
+	}
+
+	var files []backupFileInfo
+	for _, entry := range entries {
+		m := backupNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		if !isBackupKind(m[1]) && m[1] != backupMetaKind {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, backupFileInfo{
+			kind: m[1],
+			id:   m[2],
+			path: filepath.Join(backupDir, entry.Name()),
+			size: info.Size(),
+		})
+	}
+	return files
+}
+
+// isBackupKind reports whether kind is a kind of file backups contain
+func isBackupKind(kind string) bool {
+	for _, k := range allBackupKinds() {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ListBackups returns all backups, newest first
+func ListBackups() ([]*Backup, error) {
+	if _, err := os.Stat(getBackupDir()); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	byID := make(map[string]*Backup)
+	for _, file := range listBackupFiles(getBackupDir()) {
+		if _, ok := byID[file.id]; !ok {
+			byID[file.id] = newBackup(file.id)
+		}
+	}
+
+	backups := make([]*Backup, 0, len(byID))
+	for _, backup := range byID {
+		if len(backup.Files) > 0 {
+			backups = append(backups, backup)
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID > backups[j].ID
+	})
+
+	return backups, nil
+}
+
+// GetBackup returns the backup with the given id
+func GetBackup(id string) (*Backup, error) {
+	if !backupNamePattern.MatchString("settings-" + id + ".json") {
+		return nil, fmt.Errorf("invalid backup id '%s'", id)
+	}
+
+	backup := newBackup(id)
+	if len(backup.Files) == 0 {
+		return nil, fmt.Errorf("backup '%s' not found", id)
+	}
+	return backup, nil
+}
+
+// newBackup describes the backup with the given id from the files on disk
+func newBackup(id string) *Backup {
+	backupDir := getBackupDir()
+	backup := &Backup{
+		ID:    id,
+		Files: make(map[string]string),
+	}
+	backup.Created, _ = parseBackupID(id)
+
+	for _, kind := range allBackupKinds() {
+		path := backupFilePath(backupDir, kind, id)
+		if _, err := os.Stat(path); err == nil {
+			backup.Files[kind] = path
+		}
+	}
+
+	if data, err := os.ReadFile(backupFilePath(backupDir, backupMetaKind, id)); err == nil {
+		var meta backupMeta
+		if json.Unmarshal(data, &meta) == nil {
+			backup.Profile = meta.Profile
+			backup.Paths = meta.Paths
+		}
+	}
+
+	return backup
+}
+
+// Kinds returns the kinds of file the backup contains, in BackupKinds order
+// followed by project and local settings
+func (b *Backup) Kinds() []string {
+	var kinds []string
+	for _, kind := range allBackupKinds() {
+		if _, ok := b.Files[kind]; ok {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// Settings reads the settings.json stored in the backup
+func (b *Backup) Settings() (*ClaudeSettings, error) {
+	path, ok := b.Files[BackupSettings]
+	if !ok {
+		return nil, fmt.Errorf("backup '%s' does not contain settings.json", b.ID)
+	}
+	return readClaudeSettingsFile(path)
+}
+
+// RestoreBackup puts back the files of the given kinds from the backup with
+// the given id, or every file it contains if kinds is empty. Redacted
+// secrets are refilled from the backup's copy of profiles.json or the
+// profile store. The live files are backed up
+// first so the restore can be undone, and all files are written in one
+// transaction.
+func RestoreBackup(id string, kinds ...string) error {
+	backup, err := GetBackup(id)
+	if err != nil {
+		return err
+	}
+
+	if len(kinds) == 0 {
+		kinds = backup.Kinds()
+	}
+
+	index := newSecretIndex()
+	if path, ok := backup.Files[BackupProfiles]; ok {
+		if data, err := os.ReadFile(path); err == nil {
+			index.addStoreData(data)
+		}
+	}
+
+	t := newTxn()
+	for _, kind := range kinds {
+		path, ok := backup.Files[kind]
+		if !ok {
+			return fmt.Errorf("backup '%s' does not contain %s", id, kind)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("backup '%s' %s is not valid JSON", id, kind)
+		}
+		if data, err = refillBackupData(kind, data, index); err != nil {
+			return fmt.Errorf("cannot restore %s from backup '%s': %w", kind, id, err)
+		}
+
+		live := livePath(kind, backup.Paths)
+		if live == "" {
+			return fmt.Errorf("backup '%s' does not record where %s came from", id, kind)
+		}
+		t.stage(live, data, liveFileMode(kind, live))
+	}
+
+	if err := backupFilesAt(backup.Paths, kinds...); err != nil {
+		return fmt.Errorf("failed to backup current files: %w", err)
+	}
+
+	return t.commit()
+}
+
+// ChangeKind describes how a settings key differs between two files
+type ChangeKind int
+
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+// SettingsChange is one difference between two settings files. Env keys
+// are reported individually as "env.<KEY>".
+type SettingsChange struct {
+	Key  string
+	Kind ChangeKind
+	Old  string
+	New  string
+}
+
+// DiffSettings lists the changes needed to go from old to new
+func DiffSettings(old, new *ClaudeSettings) []SettingsChange {
+	var changes []SettingsChange
+
+	oldTop, newTop := topLevelValues(old), topLevelValues(new)
+	for _, key := range unionKeys(oldTop, newTop) {
+		changes = appendChange(changes, key, oldTop, newTop)
+	}
+
+	oldEnv, newEnv := envValues(old), envValues(new)
+	for _, key := range unionKeys(oldEnv, newEnv) {
+		changes = appendChange(changes, "env."+key, oldEnv, newEnv)
+	}
+
+	return changes
+}
+
+// appendChange compares key in old and new and appends any difference
+func appendChange(changes []SettingsChange, name string, old, new map[string]string) []SettingsChange {
+	key := strings.TrimPrefix(name, "env.")
+	oldValue, inOld := old[key]
+	newValue, inNew := new[key]
+
+	switch {
+	case inOld && !inNew:
+		return append(changes, SettingsChange{Key: name, Kind: ChangeRemoved, Old: oldValue})
+	case !inOld && inNew:
+		return append(changes, SettingsChange{Key: name, Kind: ChangeAdded, New: newValue})
+	case oldValue != newValue && !sameSecret(oldValue, newValue):
+		return append(changes, SettingsChange{Key: name, Kind: ChangeModified, Old: oldValue, New: newValue})
+	}
+	return changes
+}
+
+// sameSecret reports whether a and b are the same secret, one of them
+// possibly redacted
+func sameSecret(a, b string) bool {
+	return (IsRedacted(a) && redactedPlaceholder(b) == a) || (IsRedacted(b) && redactedPlaceholder(a) == b)
+}
+
+// topLevelValues returns every top-level key except env as compact JSON
+func topLevelValues(s *ClaudeSettings) map[string]string {
+	values := make(map[string]string)
+	if s.doc == nil {
+		return values
+	}
+	for _, key := range s.doc.Keys() {
+		if key == "env" {
+			continue
+		}
+		raw, _ := s.doc.Get(key)
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			values[key] = string(raw)
+		} else {
+			values[key] = buf.String()
+		}
+	}
+	return values
+}
+
+// envValues returns every env entry, with non-string values as JSON
+func envValues(s *ClaudeSettings) map[string]string {
+	values := make(map[string]string)
+	if s.env != nil {
+		for _, key := range s.env.Keys() {
+			raw, _ := s.env.Get(key)
+			var str string
+			if json.Unmarshal(raw, &str) != nil {
+				values[key] = string(raw)
+			}
+		}
+	}
+	for key, value := range s.Env {
+		values[key] = value
+	}
+	return values
+}
+
+// unionKeys returns the keys of a and b in sorted order
+func unionKeys(a, b map[string]string) []string {
+	merged := make(map[string]string, len(a)+len(b))
+	for k := range a {
+		merged[k] = ""
+	}
+	for k := range b {
+		merged[k] = ""
+	}
+	return sortedKeys(merged)
+}
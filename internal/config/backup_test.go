@@ -0,0 +1,192 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRestoreBackupIsUndoable(t *testing.T) {
+	setupClaudeHome(t, `{"model": "opus", "env": {"ANTHROPIC_MODEL": "before"}}`)
+
+	profile := NewProfile()
+	profile.SetModel("after")
+	if err := profile.ApplyToClaude(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("ListBackups: %v %v", backups, err)
+	}
+
+	old, err := backups[0].Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, err := GetCurrentClaudeSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := DiffSettings(old, live)
+	if len(changes) != 1 || changes[0].Key != "env.ANTHROPIC_MODEL" || changes[0].Kind != ChangeModified {
+		t.Fatalf("unexpected diff: %+v", changes)
+	}
+
+	data, _ := os.ReadFile(backups[0].Files[BackupSettings])
+	if err := RestoreBackup(backups[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, _ := os.ReadFile(getClaudeConfigPath())
+	if string(restored) != string(data) {
+		t.Errorf("settings.json not restored:\ngot  %s\nwant %s", restored, data)
+	}
+}
+
+func TestGetBackupRejectsInvalidID(t *testing.T) {
+	setupClaudeHome(t, "")
+	if _, err := GetBackup("../profiles"); err == nil {
+		t.Error("expected error for invalid backup id")
+	}
+}
+
+func TestPruneBackupsOnlyTouchesBackupFiles(t *testing.T) {
+	dir := t.TempDir()
+	names := map[string]bool{
+		"settings-20260101-000000.json":           false, // legacy one-second name
+		"settings-20260102-000000.000000001.json": false,
+		"meta-20260102-000000.000000001.json":     false,
+		"settings-20260103-000000.000000001.json": true,
+		"profiles-20260103-000000.000000001.json": true,
+		"meta-20260103-000000.000000001.json":     true,
+		"notes.txt":                               true,
+		"settings-mine.json":                      true,
+		"other-20260101-000000.json":              true,
+	}
+	for name := range names {
+		if err := os.WriteFile(dir+"/"+name, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruneBackups(dir, BackupRetention{MaxCount: 1}, time.Now())
+
+	for name, wantKept := range names {
+		_, err := os.Stat(dir + "/" + name)
+		if kept := err == nil; kept != wantKept {
+			t.Errorf("%s: kept=%v, want %v", name, kept, wantKept)
+		}
+	}
+}
+
+func TestLegacyBackupIDs(t *testing.T) {
+	setupClaudeHome(t, "")
+	dir := getBackupDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"settings-20260101-000000.json", "settings-20260301-000000.000000001.json"} {
+		if err := os.WriteFile(dir+"/"+name, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backup, err := GetBackup("20260101-000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local); !backup.Created.Equal(want) {
+		t.Errorf("Created = %v, want %v", backup.Created, want)
+	}
+
+	now := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	pruneBackups(dir, BackupRetention{MaxAgeDays: 30}, now)
+	if _, err := os.Stat(dir + "/settings-20260101-000000.json"); !os.IsNotExist(err) {
+		t.Errorf("legacy backup older than maxAgeDays not pruned: %v", err)
+	}
+}
+
+func TestPruneBackupsCountsEachKind(t *testing.T) {
+	dir := t.TempDir()
+	names := map[string]bool{
+		"settings-20260101-000000.000000001.json": false,
+		"profiles-20260101-000000.000000001.json": false,
+		"meta-20260101-000000.000000001.json":     false,
+		"settings-20260102-000000.000000001.json": true,
+		"profiles-20260102-000000.000000001.json": true, // expired, kept with settings
+		"meta-20260102-000000.000000001.json":     true,
+		"profiles-20260103-000000.000000001.json": false,
+		"meta-20260103-000000.000000001.json":     false,
+		"profiles-20260104-000000.000000001.json": true,
+		"meta-20260104-000000.000000001.json":     true,
+	}
+	for name := range names {
+		if err := os.WriteFile(dir+"/"+name, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Newer profiles-only backups don't push out the last settings backup
+	pruneBackups(dir, BackupRetention{MaxCount: 1}, time.Now())
+
+	for name, wantKept := range names {
+		_, err := os.Stat(dir + "/" + name)
+		if kept := err == nil; kept != wantKept {
+			t.Errorf("%s: kept=%v, want %v", name, kept, wantKept)
+		}
+	}
+}
+
+func TestBackupNamesDoNotCollide(t *testing.T) {
+	dir := t.TempDir()
+	seen := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		id, err := createBackupFile(dir, backupMetaKind, []byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate backup id %s", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestBackupCoversAllSwitchedFiles(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_MODEL": "hand-set"}}`)
+	store := newSwitchStore(t)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	profilesBefore, _ := os.ReadFile(getProfilesPath())
+
+	if _, err := store.Switch("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := ListBackups()
+	if err != nil || len(backups) == 0 {
+		t.Fatalf("ListBackups: %v %v", backups, err)
+	}
+	latest := backups[0]
+	for _, kind := range []string{BackupSettings, BackupProfiles} {
+		if _, ok := latest.Files[kind]; !ok {
+			t.Errorf("switch backup missing %s: %v", kind, latest.Files)
+		}
+	}
+
+	// Restore only profiles.json and check settings.json is left alone
+	settingsAfter, _ := os.ReadFile(getClaudeConfigPath())
+	if err := RestoreBackup(latest.ID, BackupProfiles); err != nil {
+		t.Fatal(err)
+	}
+	profilesRestored, _ := os.ReadFile(getProfilesPath())
+	if string(profilesRestored) != string(profilesBefore) {
+		t.Errorf("profiles.json not restored:\ngot  %s\nwant %s", profilesRestored, profilesBefore)
+	}
+	settingsNow, _ := os.ReadFile(getClaudeConfigPath())
+	if string(settingsNow) != string(settingsAfter) {
+		t.Errorf("settings.json changed by profiles-only restore")
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// backupSealedPrefix marks a secret in a copy of a plaintext profiles.json,
+// kept in backups and the journal, that is sealed with the backup key
+const backupSealedPrefix = "ccs-sealed:v1:"
+
+// backupAAD is the associated data for values sealed with the backup key
+const backupAAD = "ccs-backup"
+
+// getBackupKeyPath returns the path of the backup key (~/.ccs/backup.key)
+func getBackupKeyPath() string {
+	return filepath.Join(getCCSDir(), "backup.key")
+}
+
+// isBackupSealed reports whether value is sealed with the backup key
+func isBackupSealed(value string) bool {
+	return strings.HasPrefix(value, backupSealedPrefix)
+}
+
+// loadBackupKey reads the backup key, creating a random one first if there
+// is none and create is set
+func loadBackupKey(create bool) ([]byte, error) {
+	path := getBackupKeyPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(getCCSDir(), privateDirMode); err != nil {
+			return nil, err
+		}
+		err = writeNewFile(path, []byte(base64.StdEncoding.EncodeToString(key)))
+		if err == nil {
+			return key, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create backup key: %w", err)
+		}
+		data, err = os.ReadFile(path) // Created by another process meanwhile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("backup key %s is corrupt", path)
+	}
+	return key, nil
+}
+
+// sealBackupValue seals a secret with the backup key
+func sealBackupValue(key []byte, value string) (string, error) {
+	sealed, err := sealValue(key, backupAAD, value)
+	if err != nil {
+		return "", err
+	}
+	return backupSealedPrefix + strings.TrimPrefix(sealed, sealedPrefix), nil
+}
+
+// openBackupValue opens a secret sealed with the backup key
+func openBackupValue(value string) (string, error) {
+	key, err := loadBackupKey(false)
+	if err != nil {
+		return "", err
+	}
+	plain, err := openValue(key, backupAAD, sealedPrefix+strings.TrimPrefix(value, backupSealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to open sealed backup value: %w", err)
+	}
+	return plain, nil
+}
+
+// sealStoreData seals the secrets in a copy of profiles.json with the
+// backup key, reporting whether anything was sealed. The values of an
+// encrypted store are sealed with the store key already and are left as
+// they are.
+func sealStoreData(data []byte, isSecret func(string) bool) ([]byte, bool, error) {
+	var header struct {
+		Encryption *StoreEncryption `json:"encryption"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, false, err
+	}
+	if header.Encryption != nil {
+		return data, false, nil
+	}
+
+	var key []byte
+	return mapSecrets(BackupProfiles, data, isSecret, func(value string) (string, error) {
+		if !redactable(value) {
+			return value, nil
+		}
+		if key == nil {
+			var err error
+			if key, err = loadBackupKey(true); err != nil {
+				return "", err
+			}
+		}
+		return sealBackupValue(key, value)
+	})
+}
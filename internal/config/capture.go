@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// captureEnvKeys are the documented Claude Code variables CaptureEnviron
+// takes besides EnvKeys and other ANTHROPIC_ variables. Most CLAUDE_CODE_
+// variables in a shell describe a running session, so they aren't matched
+// by prefix.
+var captureEnvKeys = map[string]bool{
+	"API_TIMEOUT_MS":                           true,
+	"BASH_DEFAULT_TIMEOUT_MS":                  true,
+	"BASH_MAX_TIMEOUT_MS":                      true,
+	"CLAUDE_CODE_MAX_OUTPUT_TOKENS":            true,
+	"CLAUDE_CODE_USE_BEDROCK":                  true,
+	"CLAUDE_CODE_USE_VERTEX":                   true,
+	"CLAUDE_CODE_SKIP_BEDROCK_AUTH":            true,
+	"CLAUDE_CODE_SKIP_VERTEX_AUTH":             true,
+	"CLAUDE_CODE_DISABLE_NONESSENTIAL_TRAFFIC": true,
+	"DISABLE_TELEMETRY":                        true,
+	"DISABLE_ERROR_REPORTING":                  true,
+	"MAX_THINKING_TOKENS":                      true,
+	"HTTP_PROXY":                               true,
+	"HTTPS_PROXY":                              true,
+	"NO_PROXY":                                 true,
+	"AWS_REGION":                               true,
+	"CLOUD_ML_REGION":                          true,
+}
+
+// captureEnvSkip are variables ccs itself sets, which never belong in a
+// profile
+var captureEnvSkip = map[string]bool{
+	SessionEnv:         true,
+	ClaudeConfigDirEnv: true,
+	PassphraseEnv:      true,
+}
+
+// isKnownKey reports whether key is one of EnvKeys or a token key
+func isKnownKey(key string) bool {
+	for _, k := range EnvKeys {
+		if k == key {
+			return true
+		}
+	}
+	return isTokenKey(key)
+}
+
+// CaptureSettings builds a profile from the string env values in
+// settings, or only from EnvKeys and token keys if onlyKnown is set
+func CaptureSettings(settings *ClaudeSettings, onlyKnown bool) *Profile {
+	profile := NewProfile()
+	for key, value := range settings.Env {
+		if !onlyKnown || isKnownKey(key) {
+			profile.SetEnv(key, value)
+		}
+	}
+	return profile
+}
+
+// CaptureEnviron builds a profile from environ (as from os.Environ): the
+// EnvKeys and token keys, plus other ANTHROPIC_ variables and documented
+// Claude Code settings such as HTTPS_PROXY unless onlyKnown is set
+func CaptureEnviron(environ []string, onlyKnown bool) *Profile {
+	profile := NewProfile()
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || value == "" || captureEnvSkip[key] {
+			continue
+		}
+		capture := isKnownKey(key)
+		if !onlyKnown {
+			capture = capture || strings.HasPrefix(key, "ANTHROPIC_") || captureEnvKeys[key]
+		}
+		if capture {
+			profile.SetEnv(key, value)
+		}
+	}
+	return profile
+}
+
+// CaptureBackup builds a profile like CaptureSettings from a settings file
+// in a backup, given by backup id or by path. Redacted secrets are filled
+// back in from the live settings and the profile store.
+func CaptureBackup(idOrPath string, onlyKnown bool) (*Profile, error) {
+	var settings *ClaudeSettings
+	if backup, err := GetBackup(idOrPath); err == nil {
+		if settings, err = backup.Settings(); err != nil {
+			return nil, err
+		}
+	} else if _, statErr := os.Stat(idOrPath); statErr == nil {
+		if settings, err = readClaudeSettingsFile(idOrPath); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, err
+	}
+
+	profile := CaptureSettings(settings, onlyKnown)
+	index := newSecretIndex()
+	for key, value := range profile.Env {
+		if !IsRedacted(value) {
+			continue
+		}
+		secret, err := index.lookup(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		profile.SetEnv(key, secret)
+	}
+	return profile, nil
+}
+
+// AdoptCaptured adds profile as name and makes it active for the
+// settings.json it was captured from, without rewriting that file: the
+// ledger takes over its keys as if ccs had created them, so switching away
+// removes them. Keys ccs already owned keep their recorded original.
+func (s *Store) AdoptCaptured(name string, profile *Profile) error {
+	previous := s.Current
+	if err := s.AddProfile(name, profile); err != nil {
+		return err
+	}
+
+	ledger, err := loadOwnership()
+	if err != nil {
+		s.Current = previous
+		delete(s.Profiles, name)
+		return err
+	}
+	for key, value := range profile.Env {
+		entry, owned := ledger.Env[key]
+		if !owned {
+			entry = &OwnershipEntry{}
+			ledger.Env[key] = entry
+		}
+		entry.Applied = value
+	}
+
+	if err := backupFiles(BackupOwnership, BackupProfiles); err != nil {
+		s.Current = previous
+		delete(s.Profiles, name)
+		return fmt.Errorf("failed to backup current files: %w", err)
+	}
+
+	s.Current = name
+
+	t := newTxn()
+	err = ledger.stage(t)
+	if err == nil {
+		err = s.stage(t)
+	}
+	if err == nil {
+		err = t.commit()
+	}
+	if err != nil {
+		s.Current = previous
+		delete(s.Profiles, name)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestAdoptCapturedOwnsLiveValues(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_AUTH_TOKEN": "sk-live", "HTTPS_PROXY": "http://proxy"}}`)
+
+	settings, err := GetCurrentClaudeSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if known := CaptureSettings(settings, true); len(known.Env) != 1 {
+		t.Errorf("--only-known captured %v", known.Env)
+	}
+
+	store := newSwitchStore(t)
+	if err := store.AdoptCaptured("live", CaptureSettings(settings, false)); err != nil {
+		t.Fatal(err)
+	}
+	if store.Current != "live" {
+		t.Errorf("current = %q, want live", store.Current)
+	}
+	if report, err := store.CheckDrift(); err != nil || report.Drifted() {
+		t.Errorf("captured profile drifted: %+v %v", report, err)
+	}
+
+	// Switching away removes the captured values rather than keeping them
+	// as originals
+	if _, err := store.Switch("first"); err != nil {
+		t.Fatal(err)
+	}
+	env := readSettingsMap(t)["env"].(map[string]any)
+	if _, ok := env["HTTPS_PROXY"]; ok {
+		t.Errorf("captured value left behind: %v", env)
+	}
+	if _, ok := env["ANTHROPIC_AUTH_TOKEN"]; ok {
+		t.Errorf("captured token left behind: %v", env)
+	}
+}
+
+func TestCaptureEnvironSkipsUnrelatedVariables(t *testing.T) {
+	environ := []string{
+		"ANTHROPIC_BASE_URL=https://api.example",
+		"ANTHROPIC_SMALL_FAST_MODEL=small",
+		"HTTPS_PROXY=http://proxy",
+		"CLAUDE_CODE_SESSION_ID=abc",
+		"PATH=/usr/bin",
+		"CCS_PROFILE=work",
+		"ANTHROPIC_MODEL=",
+	}
+
+	profile := CaptureEnviron(environ, false)
+	want := map[string]string{
+		"ANTHROPIC_BASE_URL":         "https://api.example",
+		"ANTHROPIC_SMALL_FAST_MODEL": "small",
+		"HTTPS_PROXY":                "http://proxy",
+	}
+	if len(profile.Env) != len(want) {
+		t.Errorf("captured %v, want %v", profile.Env, want)
+	}
+	for key, value := range want {
+		if profile.Env[key] != value {
+			t.Errorf("%s = %q, want %q", key, profile.Env[key], value)
+		}
+	}
+
+	if known := CaptureEnviron(environ, true); len(known.Env) != 1 || known.Env[EnvBaseURL] == "" {
+		t.Errorf("--only-known captured %v", known.Env)
+	}
+}
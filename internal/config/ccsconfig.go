@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Config holds ccs's own settings, read from ~/.ccs/config.json
+type Config struct {
+	Backups BackupRetention `json:"backups"`
+	// LockTimeoutSeconds is how long to wait for another ccs process to
+	// release the state lock
+	LockTimeoutSeconds int `json:"lockTimeoutSeconds"`
+	// AgentTimeoutMinutes is how long ccs agent keeps an unlocked key;
+	// 0 keeps it until 'ccs agent lock'
+	AgentTimeoutMinutes int `json:"agentTimeoutMinutes"`
+	// ApplyMode is how a profile's token reaches Claude Code: "env" writes
+	// it into settings.json, "apiKeyHelper" has Claude Code run 'ccs token'
+	ApplyMode string `json:"applyMode"`
+	// SecretPatterns are glob patterns (e.g. "*_SECRET") for extra env keys
+	// whose values are redacted in backups
+	SecretPatterns []string `json:"secretPatterns"`
+}
+
+// BackupRetention controls how many backups are kept. A zero limit is
+// disabled; the newest backup of each kind is always kept.
+type BackupRetention struct {
+	// MaxCount is the number of backups of each kind to keep
+	MaxCount int `json:"maxCount"`
+	// MaxAgeDays removes backups older than this many days
+	MaxAgeDays int `json:"maxAgeDays"`
+	// MaxTotalSizeMB caps the total size of backups of each kind
+	MaxTotalSizeMB int `json:"maxTotalSizeMB"`
+}
+
+// DefaultConfig returns the settings used when config.json is absent
+func DefaultConfig() *Config {
+	return &Config{
+		Backups: BackupRetention{
+			MaxCount: 5,
+		},
+		LockTimeoutSeconds:  10,
+		AgentTimeoutMinutes: 15,
+		ApplyMode:           ApplyModeEnv,
+	}
+}
+
+// getConfigPath returns the path to ccs's config.json
+func getConfigPath() string {
+	return filepath.Join(getCCSDir(), "config.json")
+}
+
+// LoadConfig reads ~/.ccs/config.json, filling in defaults for any
+// setting it doesn't mention
+func LoadConfig() (*Config, error) {
+	cfg := DefaultConfig()
+
+	data, err := os.ReadFile(getConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	return cfg, nil
+}
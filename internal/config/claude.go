@@ -4,86 +4,150 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
-	"time"
 )
 
-// ClaudeSettings represents Claude Code's settings.json structure
+// ClaudeSettings represents Claude Code's settings.json structure.
+// Only Env is modelled; every other key is carried through untouched.
 type ClaudeSettings struct {
 	Env map[string]string `json:"env"`
+
+	doc *jsonObject // full document as read from disk
+	env *jsonObject // original env object, including non-string values
 }
 
-// backup creates a backup of the current Claude settings
-func backupClaudeSettings() error {
-	claudePath := getClaudeConfigPath()
-	if _, err := os.Stat(claudePath); err != nil {
-		if os.IsNotExist(err) {
-			return nil // No file to backup
-		}
+// UnmarshalJSON decodes settings.json, remembering everything outside Env
+func (s *ClaudeSettings) UnmarshalJSON(data []byte) error {
+	doc := newJSONObject()
+	if err := json.Unmarshal(data, doc); err != nil {
 		return err
 	}
 
-	// Create backup directory in ~/.ccs
-	home, _ := os.UserHomeDir()
-	backupDir := filepath.Join(home, ".ccs", "backups")
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return err
+	env := newJSONObject()
+	if raw, ok := doc.Get("env"); ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, env); err != nil {
+			return fmt.Errorf("env: %w", err)
+		}
 	}
 
-	// Create backup filename with timestamp
-	timestamp := time.Now().Format("20060102-150405")
-	backupPath := filepath.Join(backupDir, "settings-"+timestamp+".json")
+	s.Env = make(map[string]string)
+	for _, key := range env.Keys() {
+		raw, _ := env.Get(key)
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			s.Env[key] = value
+		}
+	}
 
-	// Read current settings
-	data, err := os.ReadFile(claudePath)
-	if err != nil {
-		return err
+	s.doc = doc
+	s.env = env
+	return nil
+}
+
+// MarshalJSON encodes settings.json, writing back Env changes and leaving
+// every other key, value and type exactly as it was read
+func (s ClaudeSettings) MarshalJSON() ([]byte, error) {
+	doc := newJSONObject()
+	if s.doc != nil {
+		doc = s.doc.clone()
+	}
+
+	env := newJSONObject()
+	if s.env != nil {
+		for _, key := range s.env.Keys() {
+			raw, _ := s.env.Get(key)
+			var value string
+			if err := json.Unmarshal(raw, &value); err == nil {
+				// String values are owned by Env; drop the ones removed from it
+				if _, ok := s.Env[key]; !ok {
+					continue
+				}
+			}
+			env.Set(key, raw)
+		}
+	}
+	for _, key := range sortedKeys(s.Env) {
+		if err := env.Set(key, s.Env[key]); err != nil {
+			return nil, err
+		}
 	}
 
-	// Write backup
-	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		return err
+	if err := doc.Set("env", env); err != nil {
+		return nil, err
 	}
+	return json.Marshal(doc)
+}
 
-	// Clean up old backups (keep last 5)
-	rotateBackups(backupDir, 5)
+// stringSettings returns the top-level setting key as a one-entry map if it
+// holds a string, or an empty map otherwise
+func (s *ClaudeSettings) stringSettings(key string) map[string]string {
+	values := make(map[string]string)
+	if s.doc == nil {
+		return values
+	}
+	if raw, ok := s.doc.Get(key); ok {
+		var value string
+		if err := json.Unmarshal(raw, &value); err == nil {
+			values[key] = value
+		}
+	}
+	return values
+}
 
-	return nil
+// setStringSettings writes key back from values, removing it from the
+// document if values no longer holds it
+func (s *ClaudeSettings) setStringSettings(key string, values map[string]string) {
+	if s.doc == nil {
+		s.doc = newJSONObject()
+	}
+	if value, ok := values[key]; ok {
+		_ = s.doc.Set(key, value)
+	} else if _, ok := s.stringSettings(key)[key]; ok {
+		s.doc.Delete(key)
+	}
 }
 
-// rotateBackups keeps only the most recent n backups
-func rotateBackups(backupDir string, keep int) {
-	entries, err := os.ReadDir(backupDir)
+// readClaudeSettingsFile reads and parses the settings file at path.
+// A missing file yields empty settings; a file that can't be parsed is an
+// error, so it is never overwritten with a fresh document.
+func readClaudeSettingsFile(path string) (*ClaudeSettings, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return
+		if os.IsNotExist(err) {
+			return &ClaudeSettings{Env: make(map[string]string)}, nil
+		}
+		return nil, fmt.Errorf("failed to read Claude settings: %w", err)
 	}
 
-	var files []os.FileInfo
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-		files = append(files, info)
+	var settings ClaudeSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse Claude settings: %w", err)
 	}
 
-	// Sort by modification time (newest first)
-	for i := 0; i < len(files); i++ {
-		for j := i + 1; j < len(files); j++ {
-			if files[i].ModTime().Before(files[j].ModTime()) {
-				files[i], files[j] = files[j], files[i]
-			}
-		}
+	if settings.Env == nil {
+		settings.Env = make(map[string]string)
 	}
 
-	// Delete old backups
-	for i := keep; i < len(files); i++ {
-		path := filepath.Join(backupDir, files[i].Name())
-		os.Remove(path)
+	return &settings, nil
+}
+
+// writeClaudeSettingsFile writes settings to path atomically
+func writeClaudeSettingsFile(path string, settings *ClaudeSettings) error {
+	t := newTxn()
+	if err := stageClaudeSettings(t, path, settings); err != nil {
+		return err
 	}
+	return t.commit()
+}
+
+// stageClaudeSettings queues settings to be written to path by t
+func stageClaudeSettings(t *txn, path string, settings *ClaudeSettings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	t.stage(path, data, existingMode(path, privateFileMode))
+	return nil
 }
 
 // ApplyToClaude applies the profile to Claude's settings.json
@@ -95,242 +159,248 @@ func (p *Profile) ApplyToClaude() error {
 
 	// Read current Claude settings
 	claudePath := getClaudeConfigPath()
-	var settings ClaudeSettings
-
-	if data, err := os.ReadFile(claudePath); err == nil {
-		if err := json.Unmarshal(data, &settings); err != nil {
-			// If parse fails, start fresh
-			settings = ClaudeSettings{Env: make(map[string]string)}
-		}
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("failed to read Claude settings: %w", err)
-	} else {
-		settings = ClaudeSettings{Env: make(map[string]string)}
-	}
-
-	// Initialize Env if nil
-	if settings.Env == nil {
-		settings.Env = make(map[string]string)
+	settings, err := readClaudeSettingsFile(claudePath)
+	if err != nil {
+		return err
 	}
 
-	// Apply profile settings
-	for key, value := range p.Env {
-		settings.Env[key] = value
+	ledger, err := loadOwnership()
+	if err != nil {
+		return err
 	}
 
-	// Ensure directory exists
-	claudeDir := filepath.Dir(claudePath)
-	if err := os.MkdirAll(claudeDir, 0755); err != nil {
-		return fmt.Errorf("failed to create Claude config directory: %w", err)
+	helper := ""
+	if mode, err := loadApplyMode(); err != nil {
+		return err
+	} else if mode == ApplyModeAPIKeyHelper {
+		helper = apiKeyHelperCommand("")
 	}
 
-	// Write to temp file first for atomicity
-	tmpPath := claudePath + ".tmp"
-	data, err := json.MarshalIndent(settings, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal settings: %w", err)
+	if err := p.applyTo(settings, ledger, helper); err != nil {
+		return err
 	}
 
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temp file: %w", err)
+	t := newTxn()
+	if err := stageClaudeSettings(t, claudePath, settings); err != nil {
+		return err
 	}
-
-	// Atomic rename
-	if err := os.Rename(tmpPath, claudePath); err != nil {
-		return fmt.Errorf("failed to rename temp file: %w", err)
+	if err := ledger.stage(t); err != nil {
+		return err
 	}
-
-	return nil
+	return t.commit()
 }
 
-// ClearFromClaude removes the profile's env vars from Claude's settings.json
+// ClearFromClaude removes the profile's env vars from Claude's settings.json,
+// restoring any value ccs overwrote when the profile was applied. Isolated
+// profiles never touch the shared settings.json, so there is nothing to do.
 func (p *Profile) ClearFromClaude() error {
+	if p.Isolated {
+		return nil
+	}
+
 	claudePath := getClaudeConfigPath()
+	if _, err := os.Stat(claudePath); os.IsNotExist(err) {
+		return nil // No file to clear
+	}
 
-	// Read current settings
-	var settings ClaudeSettings
+	// Backup current settings first
+	if err := backupClaudeSettings(); err != nil {
+		return fmt.Errorf("failed to backup settings: %w", err)
+	}
 
-	data, err := os.ReadFile(claudePath)
+	// Read current settings
+	settings, err := readClaudeSettingsFile(claudePath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // No file to clear
-		}
-		return fmt.Errorf("failed to read Claude settings: %w", err)
+		return err
 	}
 
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return fmt.Errorf("failed to parse Claude settings: %w", err)
+	ledger, err := loadOwnership()
+	if err != nil {
+		return err
 	}
 
-	// Remove profile's env vars
-	for key := range p.Env {
-		delete(settings.Env, key)
+	p.clearFrom(settings, ledger)
+
+	t := newTxn()
+	if err := stageClaudeSettings(t, claudePath, settings); err != nil {
+		return err
 	}
+	if err := ledger.stage(t); err != nil {
+		return err
+	}
+	return t.commit()
+}
 
-	// Write to temp file first for atomicity
-	tmpPath := claudePath + ".tmp"
-	data, err = json.MarshalIndent(settings, "", "  ")
+// applyTo sets the profile's env vars in settings, remembering in ledger
+// what each key held before ccs took it over. Secret references are
+// resolved first; if any fails, settings and ledger are left untouched.
+// With a non-empty helper and a profile that has a token, the token keys
+// are left out of env and apiKeyHelper is set to helper instead.
+func (p *Profile) applyTo(settings *ClaudeSettings, ledger *Ownership, helper string) error {
+	env, err := p.ResolvedEnv()
 	if err != nil {
-		return fmt.Errorf("failed to marshal settings: %w", err)
+		return err
 	}
 
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temp file: %w", err)
+	if !p.hasToken() {
+		helper = ""
 	}
 
-	// Atomic rename
-	if err := os.Rename(tmpPath, claudePath); err != nil {
-		return fmt.Errorf("failed to rename temp file: %w", err)
+	if helper == "" {
+		ledger.releaseSetting(settings, settingAPIKeyHelper)
+	} else {
+		for _, key := range tokenKeys {
+			if _, owned := ledger.Env[key]; owned {
+				ledger.release(settings.Env, key)
+			}
+			delete(env, key)
+		}
+		ledger.claimSetting(settings, settingAPIKeyHelper, helper)
 	}
 
+	for key, value := range env {
+		ledger.claim(settings.Env, key, value)
+	}
 	return nil
 }
 
-// GetCurrentClaudeSettings reads the current Claude settings
-func GetCurrentClaudeSettings() (*ClaudeSettings, error) {
-	claudePath := getClaudeConfigPath()
-	data, err := os.ReadFile(claudePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return &ClaudeSettings{Env: make(map[string]string)}, nil
+// clearFrom restores the values the profile's env vars and apiKeyHelper
+// replaced in settings
+func (p *Profile) clearFrom(settings *ClaudeSettings, ledger *Ownership) {
+	_, helperOwned := ledger.Settings[settingAPIKeyHelper]
+	for key := range p.Env {
+		// In apiKeyHelper mode the token was never written to env
+		if _, owned := ledger.Env[key]; !owned && helperOwned && isTokenKey(key) {
+			continue
 		}
-		return nil, fmt.Errorf("failed to read Claude settings: %w", err)
-	}
-
-	var settings ClaudeSettings
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return nil, fmt.Errorf("failed to parse Claude settings: %w", err)
-	}
-
-	if settings.Env == nil {
-		settings.Env = make(map[string]string)
+		ledger.release(settings.Env, key)
 	}
+	ledger.releaseSetting(settings, settingAPIKeyHelper)
+}
 
-	return &settings, nil
+// GetCurrentClaudeSettings reads the current Claude settings
+func GetCurrentClaudeSettings() (*ClaudeSettings, error) {
+	return readClaudeSettingsFile(getClaudeConfigPath())
 }
 
-// ClaudeJSON represents the ~/.claude.json structure
-type ClaudeJSON struct {
-	HasCompletedOnboarding bool              `json:"hasCompletedOnboarding"`
-	MCPServers             map[string]any    `json:"mcpServers,omitempty"`
+// readClaudeJSON reads ~/.claude.json as a lossless document
+func readClaudeJSON() (*jsonObject, error) {
+	return readClaudeJSONFile(getClaudeJSONPath())
 }
 
-// SetHasCompletedOnboarding sets hasCompletedOnboarding=true in ~/.claude.json
-// This skips Claude Code's first-time setup confirmation
-func SetHasCompletedOnboarding() (bool, error) {
-	path := getClaudeJSONPath()
-	var config ClaudeJSON
-
-	// Read existing file if it exists
-	if data, err := os.ReadFile(path); err == nil {
-		if err := json.Unmarshal(data, &config); err != nil {
-			// If parse fails, start fresh
-			config = ClaudeJSON{}
+// readClaudeJSONFile reads a .claude.json file as a lossless document.
+// A missing file yields an empty document; a file that can't be parsed is
+// an error, so it is never replaced with a fresh one.
+func readClaudeJSONFile(path string) (*jsonObject, error) {
+	doc := newJSONObject()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return doc, nil
 		}
-	} else if !os.IsNotExist(err) {
-		return false, fmt.Errorf("failed to read ~/.claude.json: %w", err)
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
 	}
 
-	// Check if already set
-	if config.HasCompletedOnboarding {
-		return false, nil
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
 	}
 
-	// Set the flag
-	config.HasCompletedOnboarding = true
+	return doc, nil
+}
 
-	// Ensure directory exists
-	claudeDir := filepath.Dir(path)
-	if err := os.MkdirAll(claudeDir, 0755); err != nil {
-		return false, fmt.Errorf("failed to create .claude directory: %w", err)
+// editClaudeJSON loads ~/.claude.json, lets edit change it, and writes it
+// back only if edit reports a change. The file is backed up before writing.
+func editClaudeJSON(edit func(doc *jsonObject) (bool, error)) (bool, error) {
+	doc, err := readClaudeJSON()
+	if err != nil {
+		return false, err
 	}
 
-	// Write to temp file first for atomicity
-	tmpPath := path + ".tmp"
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return false, fmt.Errorf("failed to marshal config: %w", err)
+	changed, err := edit(doc)
+	if err != nil || !changed {
+		return false, err
 	}
 
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return false, fmt.Errorf("failed to write temp file: %w", err)
+	if err := backupClaudeJSON(); err != nil {
+		return false, fmt.Errorf("failed to backup ~/.claude.json: %w", err)
 	}
 
-	// Atomic rename
-	if err := os.Rename(tmpPath, path); err != nil {
-		return false, fmt.Errorf("failed to rename temp file: %w", err)
+	t := newTxn()
+	if err := stageClaudeJSON(t, doc); err != nil {
+		return false, err
+	}
+	if err := t.commit(); err != nil {
+		return false, err
 	}
 
 	return true, nil
 }
 
-// ClearHasCompletedOnboarding removes hasCompletedOnboarding from ~/.claude.json
-// This restores Claude Code's first-time setup confirmation
-func ClearHasCompletedOnboarding() (bool, error) {
-	path := getClaudeJSONPath()
-
-	// Check if file exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return false, nil
-	}
+// stageClaudeJSON queues doc to be written to ~/.claude.json by t
+func stageClaudeJSON(t *txn, doc *jsonObject) error {
+	return stageClaudeJSONFile(t, getClaudeJSONPath(), doc)
+}
 
-	// Read existing file
-	data, err := os.ReadFile(path)
+// stageClaudeJSONFile queues doc to be written to path by t
+func stageClaudeJSONFile(t *txn, path string, doc *jsonObject) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
 	if err != nil {
-		return false, fmt.Errorf("failed to read ~/.claude.json: %w", err)
+		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	var config ClaudeJSON
-	if err := json.Unmarshal(data, &config); err != nil {
-		return false, fmt.Errorf("failed to parse ~/.claude.json: %w", err)
-	}
+	t.stage(path, data, existingMode(path, privateFileMode))
+	return nil
+}
 
-	// Check if flag was set
-	if !config.HasCompletedOnboarding {
-		return false, nil
+// onboardingFlag reports the hasCompletedOnboarding value in doc
+func onboardingFlag(doc *jsonObject) bool {
+	raw, ok := doc.Get("hasCompletedOnboarding")
+	if !ok {
+		return false
 	}
+	var done bool
+	_ = json.Unmarshal(raw, &done)
+	return done
+}
 
-	// Clear the flag
-	config.HasCompletedOnboarding = false
-
-	// Write to temp file first for atomicity
-	tmpPath := path + ".tmp"
-	data, err = json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return false, fmt.Errorf("failed to marshal config: %w", err)
-	}
+// SetHasCompletedOnboarding sets hasCompletedOnboarding=true in ~/.claude.json
+// This skips Claude Code's first-time setup confirmation
+func SetHasCompletedOnboarding() (bool, error) {
+	return editClaudeJSON(setOnboardingFlag)
+}
 
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return false, fmt.Errorf("failed to write temp file: %w", err)
+// setOnboardingFlag sets hasCompletedOnboarding=true in doc, reporting
+// whether it changed
+func setOnboardingFlag(doc *jsonObject) (bool, error) {
+	// Check if already set
+	if onboardingFlag(doc) {
+		return false, nil
 	}
+	return true, doc.Set("hasCompletedOnboarding", true)
+}
 
-	// Atomic rename
-	if err := os.Rename(tmpPath, path); err != nil {
-		return false, fmt.Errorf("failed to rename temp file: %w", err)
+// ClearHasCompletedOnboarding removes hasCompletedOnboarding from ~/.claude.json
+// This restores Claude Code's first-time setup confirmation
+func ClearHasCompletedOnboarding() (bool, error) {
+	// Check if file exists
+	if _, err := os.Stat(getClaudeJSONPath()); os.IsNotExist(err) {
+		return false, nil
 	}
 
-	return true, nil
+	return editClaudeJSON(func(doc *jsonObject) (bool, error) {
+		// Check if flag was set
+		if !onboardingFlag(doc) {
+			return false, nil
+		}
+		return true, doc.Set("hasCompletedOnboarding", false)
+	})
 }
 
 // IsHasCompletedOnboarding checks if the onboarding flag is set
 func IsHasCompletedOnboarding() (bool, error) {
-	path := getClaudeJSONPath()
-
-	// Check if file exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return false, nil
-	}
-
-	// Read existing file
-	data, err := os.ReadFile(path)
+	doc, err := readClaudeJSON()
 	if err != nil {
-		return false, fmt.Errorf("failed to read ~/.claude.json: %w", err)
+		return false, err
 	}
-
-	var config ClaudeJSON
-	if err := json.Unmarshal(data, &config); err != nil {
-		return false, fmt.Errorf("failed to parse ~/.claude.json: %w", err)
-	}
-
-	return config.HasCompletedOnboarding, nil
+	return onboardingFlag(doc), nil
 }
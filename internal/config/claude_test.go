@@ -0,0 +1,316 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const settingsFixture = `{
+  "model": "opus",
+  "permissions": {
+    "allow": ["Bash(git status)", "Read(~/src/**)"],
+    "deny": []
+  },
+  "hooks": {"PreToolUse": [{"matcher": "Bash", "hooks": [{"type": "command", "command": "echo hi"}]}]},
+  "statusLine": {"type": "command", "command": "~/.claude/statusline.sh", "padding": 0},
+  "enabledPlugins": {"foo@bar": true},
+  "cleanupPeriodDays": 30,
+  "env": {
+    "KEEP_ME": "yes",
+    "ANTHROPIC_MODEL": "old-model",
+    "NUMERIC": 42
+  }
+}`
+
+func setupClaudeHome(t *testing.T, settings string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(ClaudeConfigDirEnv, "")
+
+	if settings != "" {
+		dir := filepath.Join(home, ".claude")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(settings), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return home
+}
+
+func readSettingsMap(t *testing.T) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(getClaudeConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestApplyAndClearPreserveUnknownKeys(t *testing.T) {
+	setupClaudeHome(t, settingsFixture)
+
+	var want map[string]any
+	if err := json.Unmarshal([]byte(settingsFixture), &want); err != nil {
+		t.Fatal(err)
+	}
+
+	profile := NewProfile()
+	profile.SetAuthToken("sk-test-token")
+	profile.SetModel("new-model")
+
+	if err := profile.ApplyToClaude(); err != nil {
+		t.Fatalf("ApplyToClaude: %v", err)
+	}
+
+	got := readSettingsMap(t)
+	for key, value := range want {
+		if key == "env" {
+			continue
+		}
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("key %q changed after apply: got %v, want %v", key, got[key], value)
+		}
+	}
+
+	env := got["env"].(map[string]any)
+	if env["ANTHROPIC_AUTH_TOKEN"] != "sk-test-token" || env["ANTHROPIC_MODEL"] != "new-model" {
+		t.Errorf("profile env not applied: %v", env)
+	}
+	if env["KEEP_ME"] != "yes" || env["NUMERIC"] != float64(42) {
+		t.Errorf("unrelated env keys not preserved: %v", env)
+	}
+
+	if err := profile.ClearFromClaude(); err != nil {
+		t.Fatalf("ClearFromClaude: %v", err)
+	}
+
+	got = readSettingsMap(t)
+	for key, value := range want {
+		if key == "env" {
+			continue
+		}
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("key %q changed after clear: got %v, want %v", key, got[key], value)
+		}
+	}
+
+	env = got["env"].(map[string]any)
+	if _, ok := env["ANTHROPIC_AUTH_TOKEN"]; ok {
+		t.Errorf("profile key not cleared: %v", env)
+	}
+	if env["KEEP_ME"] != "yes" || env["NUMERIC"] != float64(42) {
+		t.Errorf("unrelated env keys not preserved: %v", env)
+	}
+}
+
+func TestApplyPreservesKeyOrder(t *testing.T) {
+	setupClaudeHome(t, settingsFixture)
+
+	profile := NewProfile()
+	profile.SetBaseURL("https://example.com")
+	if err := profile.ApplyToClaude(); err != nil {
+		t.Fatalf("ApplyToClaude: %v", err)
+	}
+
+	data, err := os.ReadFile(getClaudeConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc jsonObject
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"model", "permissions", "hooks", "statusLine", "enabledPlugins", "cleanupPeriodDays", "env"}
+	if !reflect.DeepEqual(doc.Keys(), want) {
+		t.Errorf("key order changed: got %v, want %v", doc.Keys(), want)
+	}
+}
+
+func TestApplyRefusesUnparseableSettings(t *testing.T) {
+	setupClaudeHome(t, `{"permissions": {`)
+
+	profile := NewProfile()
+	profile.SetModel("m")
+	if err := profile.ApplyToClaude(); err == nil {
+		t.Fatal("expected error for unparseable settings.json")
+	}
+
+	data, err := os.ReadFile(getClaudeConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"permissions": {` {
+		t.Errorf("unparseable settings.json was overwritten: %s", data)
+	}
+}
+
+func TestOnboardingFlagPreservesClaudeJSON(t *testing.T) {
+	home := setupClaudeHome(t, "")
+	path := filepath.Join(home, ".claude.json")
+	original := `{"numStartups": 12, "projects": {"/src/app": {"hasTrustDialogAccepted": true}}, "oauthAccount": {"emailAddress": "a@b.c"}, "tipsHistory": {"x": 3}}`
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := SetHasCompletedOnboarding()
+	if err != nil || !changed {
+		t.Fatalf("SetHasCompletedOnboarding: changed=%v err=%v", changed, err)
+	}
+
+	var want, got map[string]any
+	_ = json.Unmarshal([]byte(original), &want)
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["hasCompletedOnboarding"] != true {
+		t.Errorf("flag not set: %s", data)
+	}
+	delete(got, "hasCompletedOnboarding")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("~/.claude.json changed:\ngot  %v\nwant %v", got, want)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(home, ".ccs", "backups", "claude-*[0-9].json"))
+	if len(backups) != 1 {
+		t.Errorf("expected one ~/.claude.json backup, got %v", backups)
+	}
+
+	changed, err = SetHasCompletedOnboarding()
+	if err != nil || changed {
+		t.Errorf("second SetHasCompletedOnboarding: changed=%v err=%v", changed, err)
+	}
+}
+
+func TestOnboardingFlagRefusesUnparseableClaudeJSON(t *testing.T) {
+	home := setupClaudeHome(t, "")
+	path := filepath.Join(home, ".claude.json")
+	if err := os.WriteFile(path, []byte(`{"projects": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SetHasCompletedOnboarding(); err == nil {
+		t.Fatal("expected error for unparseable ~/.claude.json")
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != `{"projects": ` {
+		t.Errorf("unparseable ~/.claude.json was overwritten: %s", data)
+	}
+}
+
+func TestClearRestoresOverwrittenValues(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_MODEL": "hand-set", "API_TIMEOUT_MS": "600000"}}`)
+
+	first := NewProfile()
+	first.SetModel("first-model")
+	first.SetAuthToken("sk-first")
+	second := NewProfile()
+	second.SetModel("second-model")
+
+	if err := first.ApplyToClaude(); err != nil {
+		t.Fatal(err)
+	}
+	// Switch first -> second, then clear second
+	if err := first.ClearFromClaude(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.ApplyToClaude(); err != nil {
+		t.Fatal(err)
+	}
+	if env := readSettingsMap(t)["env"].(map[string]any); env["ANTHROPIC_MODEL"] != "second-model" {
+		t.Fatalf("second profile not applied: %v", env)
+	}
+	if err := second.ClearFromClaude(); err != nil {
+		t.Fatal(err)
+	}
+
+	env := readSettingsMap(t)["env"].(map[string]any)
+	want := map[string]any{"ANTHROPIC_MODEL": "hand-set", "API_TIMEOUT_MS": "600000"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("env not restored: got %v, want %v", env, want)
+	}
+
+	ledger, err := loadOwnership()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Env) != 0 {
+		t.Errorf("ledger not released: %v", ledger.Env)
+	}
+}
+
+func TestClearKeepsHandEditedValues(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_MODEL": "hand-set"}}`)
+
+	profile := NewProfile()
+	profile.SetModel("profile-model")
+	if err := profile.ApplyToClaude(); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := GetCurrentClaudeSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.Env["ANTHROPIC_MODEL"] = "edited-later"
+	if err := writeClaudeSettingsFile(getClaudeConfigPath(), settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := profile.ClearFromClaude(); err != nil {
+		t.Fatal(err)
+	}
+	if env := readSettingsMap(t)["env"].(map[string]any); env["ANTHROPIC_MODEL"] != "edited-later" {
+		t.Errorf("hand-edited value overwritten: %v", env)
+	}
+}
+
+func TestAPIKeyHelperModeKeepsTokenOutOfSettings(t *testing.T) {
+	home := setupClaudeHome(t, settingsFixture)
+	if err := os.MkdirAll(filepath.Join(home, ".ccs"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(getConfigPath(), []byte(`{"applyMode": "apiKeyHelper"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newSwitchStore(t)
+	if _, err := store.Switch("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := readSettingsMap(t)
+	if settings["apiKeyHelper"] != "ccs token second" {
+		t.Errorf("apiKeyHelper = %v", settings["apiKeyHelper"])
+	}
+	env := settings["env"].(map[string]any)
+	if _, ok := env[EnvAuthToken]; ok {
+		t.Errorf("token written to env: %v", env)
+	}
+	if env[EnvModel] != "second-model" || settings["model"] != "opus" {
+		t.Errorf("other settings not applied or not kept: %v", settings)
+	}
+
+	// Switching away gives apiKeyHelper back
+	if _, err := store.Switch("first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readSettingsMap(t)["apiKeyHelper"]; ok {
+		t.Error("apiKeyHelper left behind after switching to a profile without a token")
+	}
+
+	if token, err := store.Profiles["second"].Token(); err != nil || token != "sk-second" {
+		t.Errorf("Token() = %q, %v", token, err)
+	}
+}
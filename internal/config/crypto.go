@@ -0,0 +1,218 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// PassphraseEnv is the environment variable read for the store passphrase,
+// for CI and other non-interactive use
+const PassphraseEnv = "CCS_PASSPHRASE"
+
+// sealedPrefix marks an env value encrypted with the store key
+const sealedPrefix = "enc:v1:"
+
+// passphraseCheck is sealed with the store key so a wrong passphrase can
+// be detected before any value is decrypted
+const passphraseCheck = "ccs"
+
+// ErrWrongPassphrase is returned when the passphrase doesn't unlock the store
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// errNoTerminal is returned by PassphrasePrompt when stdin isn't a terminal
+var errNoTerminal = errors.New("stdin is not a terminal")
+
+// unlockedKeys caches store keys by salt for the life of the process, so
+// reloading the store (as the TUI does before each change) doesn't prompt
+var unlockedKeys = make(map[string][]byte)
+
+// StoreEncryption describes how the env values in profiles.json are sealed.
+// Profile names stay readable; every env value is encrypted with AES-256-GCM
+// under a key derived from a passphrase with scrypt.
+type StoreEncryption struct {
+	KDF   string `json:"kdf"`
+	Salt  string `json:"salt"`
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	Check string `json:"check"`
+}
+
+// PassphrasePrompt asks the user for the store passphrase. It reads from the
+// terminal without echo and fails when stdin isn't a terminal.
+var PassphrasePrompt = func(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errNoTerminal
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(data), nil
+}
+
+// newStoreEncryption creates parameters with a fresh salt and derives the
+// key for passphrase
+func newStoreEncryption(passphrase string) (*StoreEncryption, []byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+
+	enc := &StoreEncryption{
+		KDF:  "scrypt",
+		Salt: base64.StdEncoding.EncodeToString(salt),
+		N:    1 << 15,
+		R:    8,
+		P:    1,
+	}
+
+	key, err := enc.deriveKey(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enc.Check, err = sealValue(key, "check", passphraseCheck)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unlockedKeys[enc.Salt] = key
+	agentAddKey(enc.Salt, key)
+	return enc, key, nil
+}
+
+// deriveKey derives the store key from passphrase
+func (e *StoreEncryption) deriveKey(passphrase string) ([]byte, error) {
+	if e.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation '%s'", e.KDF)
+	}
+	salt, err := base64.StdEncoding.DecodeString(e.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	return scrypt.Key([]byte(passphrase), salt, e.N, e.R, e.P, 32)
+}
+
+// unlock derives the key for passphrase and checks it against Check
+func (e *StoreEncryption) unlock(passphrase string) ([]byte, error) {
+	key, err := e.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return e.unlockWith(key)
+}
+
+// unlockWith checks key against Check
+func (e *StoreEncryption) unlockWith(key []byte) ([]byte, error) {
+	if check, err := openValue(key, "check", e.Check); err != nil || check != passphraseCheck {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+// unlockKey returns the store key, using a key already unlocked by this
+// process or held by ccs agent, or else the passphrase from the environment
+// or a prompt. A key unlocked from a passphrase is handed to the agent.
+func (e *StoreEncryption) unlockKey() ([]byte, error) {
+	if key, ok := unlockedKeys[e.Salt]; ok {
+		return key, nil
+	}
+
+	if key, ok := agentGetKey(e.Salt); ok {
+		if key, err := e.unlockWith(key); err == nil {
+			unlockedKeys[e.Salt] = key
+			return key, nil
+		}
+	}
+
+	passphrase, ok := os.LookupEnv(PassphraseEnv)
+	if !ok {
+		var err error
+		passphrase, err = PassphrasePrompt("Passphrase for ~/.ccs/profiles.json: ")
+		if errors.Is(err, errNoTerminal) {
+			return nil, fmt.Errorf("profiles.json is encrypted; set %s to unlock it non-interactively", PassphraseEnv)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, err := e.unlock(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	unlockedKeys[e.Salt] = key
+	agentAddKey(e.Salt, key)
+	return key, nil
+}
+
+// sealValue encrypts value, binding it to its location via aad
+func sealValue(key []byte, aad, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(aad))
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openValue decrypts a value produced by sealValue with the same aad
+func openValue(key []byte, aad, sealed string) (string, error) {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		return "", fmt.Errorf("value is not encrypted")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted value is truncated")
+	}
+
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// newGCM returns an AES-GCM AEAD for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// envAAD is the associated data for a sealed env value, so values can't be
+// moved between keys or profiles
+func envAAD(profile, key string) string {
+	return profile + "\x00" + key
+}
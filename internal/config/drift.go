@@ -0,0 +1,166 @@
+package config
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// KeyState says how a settings value compares with the active profile
+type KeyState string
+
+const (
+	// KeyMatching is set to the profile's value
+	KeyMatching KeyState = "matching"
+	// KeyModified is set, but to something else
+	KeyModified KeyState = "modified"
+	// KeyMissing is in the profile but not in the settings file
+	KeyMissing KeyState = "missing"
+	// KeyForeign is a key the active profile doesn't define that ccs still
+	// owns, or that holds a value another profile applied. Keys set by hand
+	// are the user's and never foreign.
+	KeyForeign KeyState = "foreign"
+)
+
+// KeyStatus is the state of one key. Want is what the profile applied and
+// Have what the settings file holds; either is "" where it doesn't apply.
+type KeyStatus struct {
+	Key   string
+	State KeyState
+	Want  string
+	Have  string
+}
+
+// DriftReport compares the active profile with the settings file it was
+// applied to
+type DriftReport struct {
+	Profile string
+	Path    string
+	Keys    []KeyStatus
+}
+
+// Drifted reports whether any key no longer matches the profile
+func (r *DriftReport) Drifted() bool {
+	for _, k := range r.Keys {
+		if k.State != KeyMatching {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDrift compares the active profile with the settings.json it was
+// applied to, which is the profile's own home for an isolated profile. It
+// returns nil if no profile is active.
+//
+// Values that came from secret references are compared with what ccs last
+// wrote, so references aren't resolved. In apiKeyHelper mode the token is
+// expected to be absent from env, and apiKeyHelper is checked instead.
+func (s *Store) CheckDrift() (*DriftReport, error) {
+	if s.Current == "" {
+		return nil, nil
+	}
+	profile, err := s.GetProfile(s.Current)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{Profile: s.Current, Path: getClaudeConfigPath()}
+	ledger, err := loadOwnership()
+	if err != nil {
+		return nil, err
+	}
+	expectHelper := false
+	if profile.Isolated {
+		report.Path = filepath.Join(getIsolatedHome(s.Current), "settings.json")
+		ledger = ledger.scope(report.Path)
+		mode, err := loadApplyMode()
+		if err != nil {
+			return nil, err
+		}
+		expectHelper = mode == ApplyModeAPIKeyHelper
+	} else {
+		_, expectHelper = ledger.Settings[settingAPIKeyHelper]
+	}
+	expectHelper = expectHelper && profile.hasToken()
+
+	settings, err := readClaudeSettingsFile(report.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range sortedKeys(profile.Env) {
+		if expectHelper && isTokenKey(key) {
+			continue
+		}
+
+		want := profile.Env[key]
+		compare := true
+		if IsSecretRef(want) {
+			entry, owned := ledger.Env[key]
+			want, compare = "", owned
+			if owned {
+				want = entry.Applied
+			}
+		}
+
+		have, present := settings.Env[key]
+		status := KeyStatus{Key: key, State: KeyMatching, Want: want, Have: have}
+		switch {
+		case !present:
+			status.State = KeyMissing
+		case compare && have != want:
+			status.State = KeyModified
+		}
+		report.Keys = append(report.Keys, status)
+	}
+
+	if expectHelper {
+		want := apiKeyHelperCommand(s.Current)
+		if entry, owned := ledger.Settings[settingAPIKeyHelper]; owned {
+			want = entry.Applied
+		}
+		have, present := settings.stringSettings(settingAPIKeyHelper)[settingAPIKeyHelper]
+		status := KeyStatus{Key: settingAPIKeyHelper, State: KeyMatching, Want: want, Have: have}
+		switch {
+		case !present:
+			status.State = KeyMissing
+		case have != want:
+			status.State = KeyModified
+		}
+		report.Keys = append(report.Keys, status)
+	}
+
+	// Keys the profile doesn't set that ccs still owns or another profile
+	// put there; applying a profile leaves hand-set keys alone, so they
+	// aren't drift
+	var foreign []string
+	for key, have := range settings.Env {
+		_, inProfile := profile.Env[key]
+		if inProfile && !(expectHelper && isTokenKey(key)) {
+			continue
+		}
+		if _, owned := ledger.Env[key]; owned || s.appliedByOther(key, have) {
+			foreign = append(foreign, key)
+		}
+	}
+	sort.Strings(foreign)
+	for _, key := range foreign {
+		report.Keys = append(report.Keys, KeyStatus{Key: key, State: KeyForeign, Have: settings.Env[key]})
+	}
+
+	return report, nil
+}
+
+// appliedByOther reports whether a profile other than the active one sets
+// key to value
+func (s *Store) appliedByOther(key, value string) bool {
+	for name, profile := range s.Profiles {
+		if name == s.Current {
+			continue
+		}
+		if other, ok := profile.Env[key]; ok && other == value && !IsSecretRef(other) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func driftStates(t *testing.T, store *Store) map[string]KeyState {
+	t.Helper()
+	report, err := store.CheckDrift()
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[string]KeyState)
+	for _, k := range report.Keys {
+		states[k.Key] = k.State
+	}
+	return states
+}
+
+func TestCheckDriftReportsEachState(t *testing.T) {
+	setupClaudeHome(t, "")
+	store := newSwitchStore(t)
+	store.Profiles["second"].SetBaseURL("https://second.example")
+	store.Profiles["first"].SetEnv(EnvDefaultOpusModel, "first-opus")
+	if _, err := store.Switch("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.CheckDrift()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Drifted() {
+		t.Fatalf("fresh switch reported as drifted: %+v", report.Keys)
+	}
+
+	edited := `{"env": {"ANTHROPIC_MODEL": "hand-set", "ANTHROPIC_AUTH_TOKEN": "sk-second", "ANTHROPIC_DEFAULT_OPUS_MODEL": "first-opus"}}`
+	if err := os.WriteFile(getClaudeConfigPath(), []byte(edited), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]KeyState{
+		EnvAuthToken:        KeyMatching,
+		EnvModel:            KeyModified,
+		EnvBaseURL:          KeyMissing,
+		EnvDefaultOpusModel: KeyForeign,
+	}
+	states := driftStates(t, store)
+	for key, state := range want {
+		if states[key] != state {
+			t.Errorf("%s = %q, want %q", key, states[key], state)
+		}
+	}
+	if len(states) != len(want) {
+		t.Errorf("unexpected keys reported: %v", states)
+	}
+}
+
+func TestCheckDriftIgnoresHandSetKeys(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_DEFAULT_HAIKU_MODEL": "mine", "HTTPS_PROXY": "http://proxy"}}`)
+	store := newSwitchStore(t)
+	if _, err := store.Switch("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.CheckDrift()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Drifted() {
+		t.Errorf("hand-set keys reported as drift: %+v", report.Keys)
+	}
+}
+
+func TestCheckDriftComparesReferencesWithAppliedValue(t *testing.T) {
+	setupClaudeHome(t, "")
+	t.Setenv("CCS_TEST_TOKEN", "sk-from-env")
+	store := newSwitchStore(t)
+	store.Profiles["second"].SetAuthToken("env:CCS_TEST_TOKEN")
+	if _, err := store.Switch("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rotated secret isn't drift until settings.json itself changes
+	t.Setenv("CCS_TEST_TOKEN", "sk-rotated")
+	if states := driftStates(t, store); states[EnvAuthToken] != KeyMatching {
+		t.Errorf("token = %q, want matching", states[EnvAuthToken])
+	}
+
+	if err := os.WriteFile(getClaudeConfigPath(), []byte(`{"env": {"ANTHROPIC_AUTH_TOKEN": "sk-other", "ANTHROPIC_MODEL": "second-model"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if states := driftStates(t, store); states[EnvAuthToken] != KeyModified {
+		t.Errorf("token = %q, want modified", states[EnvAuthToken])
+	}
+}
+
+func TestCheckDriftNoActiveProfile(t *testing.T) {
+	setupClaudeHome(t, "")
+	report, err := NewStore().CheckDrift()
+	if err != nil || report != nil {
+		t.Errorf("CheckDrift() = %v, %v; want nil, nil", report, err)
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Encrypted reports whether env values are encrypted at rest
+func (s *Store) Encrypted() bool {
+	return s.Encryption != nil
+}
+
+// unseal decrypts every env value in place with key
+func (s *Store) unseal(key []byte) error {
+	if _, err := s.Encryption.unlockWith(key); err != nil {
+		return err
+	}
+
+	for name, profile := range s.Profiles {
+		for envKey, value := range profile.Env {
+			plain, err := openValue(key, envAAD(name, envKey), value)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s of profile '%s': %w", envKey, name, err)
+			}
+			profile.Env[envKey] = plain
+		}
+	}
+
+	s.key = key
+	return nil
+}
+
+// sealed returns a copy of the store with every env value encrypted
+func (s *Store) sealed() (*Store, error) {
+	if s.key == nil {
+		return nil, fmt.Errorf("store is locked")
+	}
+
+	out := &Store{
+		Current:    s.Current,
+		Scoped:     s.Scoped,
+		Profiles:   make(map[string]*Profile, len(s.Profiles)),
+		Encryption: s.Encryption,
+	}
+	for name, profile := range s.Profiles {
+		sealed := NewProfile()
+		sealed.Isolated = profile.Isolated
+		for envKey, value := range profile.Env {
+			v, err := sealValue(s.key, envAAD(name, envKey), value)
+			if err != nil {
+				return nil, err
+			}
+			sealed.Env[envKey] = v
+		}
+		out.Profiles[name] = sealed
+	}
+	return out, nil
+}
+
+// SetPassphrase encrypts the store with a key derived from passphrase, or
+// re-encrypts it under a new one if it is already encrypted, and writes it
+// to disk. The plaintext profiles.json is not kept as a backup, and the
+// copies in backups and the journal are re-encrypted under the new key.
+func (s *Store) SetPassphrase(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+
+	enc, key, err := newStoreEncryption(passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	previous, previousKey := s.Encryption, s.key
+	s.Encryption, s.key = enc, key
+
+	t := newTxn()
+	err = s.stage(t)
+	if err == nil {
+		err = t.commit()
+	}
+	if err != nil {
+		s.Encryption, s.key = previous, previousKey
+		return err
+	}
+
+	if err := s.resealCopies(previous, previousKey); err != nil {
+		return fmt.Errorf("profiles encrypted, but failed to re-encrypt their backups: %w", err)
+	}
+	return nil
+}
+
+// resealCopies encrypts the copies of profiles.json kept in backups and the
+// journal under the store key. Plaintext copies and ones encrypted with
+// previous are converted; copies under an older key, or whose secrets were
+// redacted to placeholders, hold no readable token and are left alone.
+func (s *Store) resealCopies(previous *StoreEncryption, previousKey []byte) error {
+	for _, file := range listBackupFiles(getBackupDir()) {
+		if file.kind != BackupProfiles {
+			continue
+		}
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return err
+		}
+		resealed, changed, err := s.resealCopy(data, previous, previousKey)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.path, err)
+		}
+		if changed {
+			if err := writeFileAtomic(file.path, resealed, privateFileMode); err != nil {
+				return err
+			}
+		}
+	}
+
+	entries, err := History()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		changed := false
+		for i := range entry.Files {
+			f := &entry.Files[i]
+			if f.Kind != BackupProfiles || !f.Existed {
+				continue
+			}
+			resealed, ok, err := s.resealCopy(f.Before, previous, previousKey)
+			if err != nil {
+				return fmt.Errorf("journal entry '%s': %w", entry.Command, err)
+			}
+			if ok {
+				f.Before, changed = resealed, true
+			}
+		}
+		if changed {
+			if err := writeJournalEntry(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resealCopy encrypts one copy of profiles.json under the store key,
+// reporting whether it was changed
+func (s *Store) resealCopy(data []byte, previous *StoreEncryption, previousKey []byte) ([]byte, bool, error) {
+	var copied Store
+	if json.Unmarshal(data, &copied) != nil {
+		return data, false, nil
+	}
+
+	if copied.Encryption != nil {
+		if previous == nil || copied.Encryption.Salt != previous.Salt {
+			return data, false, nil
+		}
+		if err := copied.unseal(previousKey); err != nil {
+			return nil, false, err
+		}
+	} else {
+		for _, profile := range copied.Profiles {
+			if profile == nil {
+				continue
+			}
+			for key, value := range profile.Env {
+				if IsRedacted(value) {
+					return data, false, nil
+				}
+				if isBackupSealed(value) {
+					plain, err := openBackupValue(value)
+					if err != nil {
+						return nil, false, err
+					}
+					profile.Env[key] = plain
+				}
+			}
+		}
+	}
+
+	for name, profile := range copied.Profiles {
+		if profile == nil {
+			delete(copied.Profiles, name)
+		}
+	}
+	copied.Encryption, copied.key = s.Encryption, s.key
+	sealed, err := copied.sealed()
+	if err != nil {
+		return nil, false, err
+	}
+	out, err := json.MarshalIndent(sealed, "", "  ")
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// RemovePassphrase stores env values in plaintext again and writes the
+// store to disk
+func (s *Store) RemovePassphrase() error {
+	if s.Encryption == nil {
+		return fmt.Errorf("profiles are not encrypted")
+	}
+
+	previous, previousKey := s.Encryption, s.key
+	s.Encryption, s.key = nil, nil
+
+	if err := s.Save(); err != nil {
+		s.Encryption, s.key = previous, previousKey
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedStoreRoundTrip(t *testing.T) {
+	setupClaudeHome(t, "")
+	store := newSwitchStore(t)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.SetPassphrase("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(getProfilesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-second") || strings.Contains(string(data), "second-model") {
+		t.Fatalf("plaintext value in encrypted store: %s", data)
+	}
+
+	// Forget keys unlocked by this process so Load has to use the passphrase
+	clear(unlockedKeys)
+
+	t.Setenv(PassphraseEnv, "wrong")
+	if _, err := Load(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+
+	t.Setenv(PassphraseEnv, "correct horse")
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := loaded.Profiles["second"].GetAuthToken(); token != "sk-second" {
+		t.Errorf("token not decrypted: %q", token)
+	}
+
+	if err := loaded.RemovePassphrase(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(getProfilesPath())
+	if !strings.Contains(string(data), "sk-second") || strings.Contains(string(data), "encryption") {
+		t.Errorf("store not decrypted: %s", data)
+	}
+}
+
+func TestSealedValuesAreBoundToTheirKey(t *testing.T) {
+	_, key, err := newStoreEncryption("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := sealValue(key, envAAD("a", EnvAuthToken), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openValue(key, envAAD("b", EnvAuthToken), sealed); err == nil {
+		t.Error("value moved to another profile should not decrypt")
+	}
+	if plain, err := openValue(key, envAAD("a", EnvAuthToken), sealed); err != nil || plain != "secret" {
+		t.Errorf("openValue = %q, %v", plain, err)
+	}
+}
+
+func TestSetPassphraseReencryptsCopies(t *testing.T) {
+	setupClaudeHome(t, "")
+	store := newSwitchStore(t)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record("set second", func() error {
+		_, err := store.UpdateProfile("second", map[string]string{"FOO": "bar"}, nil)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// copies returns every copy of profiles.json in backups and the journal
+	copies := func() []string {
+		var all []string
+		for _, file := range listBackupFiles(getBackupDir()) {
+			if file.kind == BackupProfiles {
+				data, _ := os.ReadFile(file.path)
+				all = append(all, string(data))
+			}
+		}
+		entries, _ := History()
+		for _, entry := range entries {
+			for _, f := range entry.Files {
+				if f.Kind == BackupProfiles {
+					all = append(all, string(f.Before))
+				}
+			}
+		}
+		return all
+	}
+	if len(copies()) < 2 {
+		t.Fatalf("expected copies in backups and the journal, got %d", len(copies()))
+	}
+
+	for _, passphrase := range []string{"first passphrase", "second passphrase"} {
+		if err := store.SetPassphrase(passphrase); err != nil {
+			t.Fatal(err)
+		}
+		for _, data := range copies() {
+			if !strings.Contains(data, store.Encryption.Salt) || strings.Contains(data, "sk-second") || strings.Contains(data, backupSealedPrefix) {
+				t.Errorf("copy not encrypted under the current key: %s", data)
+			}
+		}
+	}
+
+	// A re-encrypted copy restores with the current passphrase
+	clear(unlockedKeys)
+	t.Setenv(PassphraseEnv, "second passphrase")
+	backups, _ := ListBackups()
+	if err := RestoreBackup(backups[0].ID, BackupProfiles); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := loaded.Profiles["second"].GetAuthToken(); token != "sk-second" {
+		t.Errorf("token = %q", token)
+	}
+}
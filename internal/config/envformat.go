@@ -0,0 +1,162 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Output formats for FormatEnv
+const (
+	FormatSh            = "sh"
+	FormatFish          = "fish"
+	FormatPowerShell    = "powershell"
+	FormatDotenv        = "dotenv"
+	FormatJSON          = "json"
+	FormatDockerEnvFile = "docker-env-file"
+	FormatGitHubActions = "github-actions"
+)
+
+// EnvFormats lists the formats FormatEnv supports
+var EnvFormats = []string{
+	FormatSh,
+	FormatFish,
+	FormatPowerShell,
+	FormatDotenv,
+	FormatJSON,
+	FormatDockerEnvFile,
+	FormatGitHubActions,
+}
+
+// envNamePattern matches variable names every format can carry
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// FormatEnv writes env to w in format, sorted by key, quoted and escaped
+// so the output can be evaluated by a shell or read by the target tool
+func FormatEnv(w io.Writer, format string, env map[string]string) error {
+	for key := range env {
+		if !envNamePattern.MatchString(key) {
+			return fmt.Errorf("'%s' is not a valid environment variable name", key)
+		}
+	}
+
+	if format == FormatJSON {
+		return writeJSONEnv(w, env)
+	}
+
+	for _, key := range sortedKeys(env) {
+		line, err := formatEnvLine(format, key, env[key])
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatUnset writes commands to w that remove keys in format. Only the
+// shell formats and json (as nulls) can express removal.
+func FormatUnset(w io.Writer, format string, keys []string) error {
+	if format == FormatJSON {
+		nulls := make(map[string]any, len(keys))
+		for _, key := range keys {
+			nulls[key] = nil
+		}
+		return writeJSONEnv(w, nulls)
+	}
+
+	for _, key := range keys {
+		if !envNamePattern.MatchString(key) {
+			return fmt.Errorf("'%s' is not a valid environment variable name", key)
+		}
+
+		var line string
+		switch format {
+		case FormatSh:
+			line = "unset " + key
+		case FormatFish:
+			line = "set -e " + key
+		case FormatPowerShell:
+			line = "Remove-Item Env:" + key + " -ErrorAction SilentlyContinue"
+		default:
+			return fmt.Errorf("format '%s' cannot unset variables", format)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatEnvLine renders one variable in a line-based format
+func formatEnvLine(format, key, value string) (string, error) {
+	switch format {
+	case FormatSh:
+		return "export " + key + "=" + shQuote(value), nil
+	case FormatFish:
+		return "set -gx " + key + " " + fishQuote(value), nil
+	case FormatPowerShell:
+		return "$Env:" + key + " = '" + strings.ReplaceAll(value, "'", "''") + "'", nil
+	case FormatDotenv:
+		return key + "=" + dotenvQuote(value), nil
+	case FormatDockerEnvFile:
+		// Docker reads values literally, with no quoting or escapes
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("%s contains a newline, which %s cannot represent", key, format)
+		}
+		return key + "=" + value, nil
+	case FormatGitHubActions:
+		if !strings.ContainsAny(value, "\r\n") {
+			return key + "=" + value, nil
+		}
+		// Multi-line values need a delimiter that can't occur in the value
+		delimiter, err := randomDelimiter()
+		if err != nil {
+			return "", err
+		}
+		return key + "<<" + delimiter + "\n" + value + "\n" + delimiter, nil
+	}
+	return "", fmt.Errorf("unknown format '%s' (want one of %s)", format, strings.Join(EnvFormats, ", "))
+}
+
+// shQuote single-quotes value for POSIX shells
+func shQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote single-quotes value for fish, where \ and ' are escaped
+func fishQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// dotenvQuote double-quotes value, escaping what dotenv parsers expand
+func dotenvQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// randomDelimiter returns a heredoc delimiter for GITHUB_ENV
+func randomDelimiter() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "ccs_" + hex.EncodeToString(b), nil
+}
+
+// writeJSONEnv writes v as an indented JSON object
+func writeJSONEnv(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
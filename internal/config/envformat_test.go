@@ -0,0 +1,50 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatEnvQuoting(t *testing.T) {
+	env := map[string]string{"TOKEN": `it's a "$secret"`}
+
+	cases := map[string]string{
+		FormatSh:            `export TOKEN='it'\''s a "$secret"'`,
+		FormatFish:          `set -gx TOKEN 'it\'s a "$secret"'`,
+		FormatPowerShell:    `$Env:TOKEN = 'it''s a "$secret"'`,
+		FormatDotenv:        `TOKEN="it's a \"\$secret\""`,
+		FormatDockerEnvFile: `TOKEN=it's a "$secret"`,
+		FormatGitHubActions: `TOKEN=it's a "$secret"`,
+		FormatJSON:          "{\n  \"TOKEN\": \"it's a \\\"$secret\\\"\"\n}",
+	}
+	for format, want := range cases {
+		var buf bytes.Buffer
+		if err := FormatEnv(&buf, format, env); err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", format, got, want)
+		}
+	}
+}
+
+func TestFormatEnvRejectsUnsafeInput(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FormatEnv(&buf, FormatSh, map[string]string{"BAD;rm": "x"}); err == nil {
+		t.Error("expected error for an invalid variable name")
+	}
+	if err := FormatEnv(&buf, FormatDockerEnvFile, map[string]string{"K": "a\nb"}); err == nil {
+		t.Error("expected error for a newline in a docker env file")
+	}
+
+	buf.Reset()
+	if err := FormatEnv(&buf, FormatGitHubActions, map[string]string{"K": "a\nb"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "K<<") || lines[3] != strings.TrimPrefix(lines[0], "K<<") {
+		t.Errorf("unexpected multi-line output: %q", buf.String())
+	}
+}
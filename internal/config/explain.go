@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Layers Claude Code takes env values from, from highest precedence to
+// lowest. Env values from any settings file are applied on top of the
+// environment Claude Code was started with.
+const (
+	LayerManaged     = "managed"
+	LayerLocal       = "local"
+	LayerProject     = "project"
+	LayerUser        = "user"
+	LayerEnvironment = "environment"
+)
+
+// managedSettingsPath returns the path of the enterprise managed settings
+// file, which overrides every other layer
+var managedSettingsPath = func() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "/Library/Application Support/ClaudeCode/managed-settings.json"
+	case "windows":
+		return `C:\Program Files\ClaudeCode\managed-settings.json`
+	}
+	return "/etc/claude-code/managed-settings.json"
+}
+
+// LayerValue is the value one layer gives a key
+type LayerValue struct {
+	Layer string
+	// Source is the file the value was read from, or "" for the environment
+	Source string
+	Value  string
+	// Profile is the ccs profile that set the value, or "" if none did
+	Profile string
+}
+
+// Explanation lists the values a key has across the layers, highest
+// precedence first. The first value is the one Claude Code uses.
+type Explanation struct {
+	Key    string
+	Values []LayerValue
+}
+
+// Effective returns the value Claude Code uses, or nil if no layer sets
+// the key
+func (e *Explanation) Effective() *LayerValue {
+	if len(e.Values) == 0 {
+		return nil
+	}
+	return &e.Values[0]
+}
+
+// settingsLayer is a settings file read for Explain
+type settingsLayer struct {
+	name     string
+	path     string
+	settings *ClaudeSettings
+	// ledger and profile identify values ccs applied to the file; a
+	// direct layer was written by profile without a ledger
+	ledger  *Ownership
+	profile string
+	direct  bool
+}
+
+// setBy returns the profile that set key to value in the layer, or ""
+func (l *settingsLayer) setBy(s *Store, key, value string) string {
+	if l.direct {
+		if s.profileSets(l.profile, key, value) {
+			return l.profile
+		}
+		return ""
+	}
+	if l.ledger == nil {
+		return ""
+	}
+	if entry, owned := l.ledger.Env[key]; owned && entry.Applied == value {
+		return l.profile
+	}
+	return ""
+}
+
+// Explain resolves keys the way Claude Code would when started in dir with
+// the current environment, reporting every layer that sets each key. Files
+// that can't be read are skipped and returned in errs, keyed by path.
+func (s *Store) Explain(dir string, keys []string) ([]*Explanation, map[string]error) {
+	errs := make(map[string]error)
+	ledger, err := loadOwnership()
+	if err != nil {
+		errs[getOwnershipPath()] = err
+		ledger = newOwnership()
+	}
+
+	var layers []*settingsLayer
+	add := func(layer *settingsLayer) {
+		settings, err := readClaudeSettingsFile(layer.path)
+		if err != nil {
+			errs[layer.path] = err
+			return
+		}
+		layer.settings = settings
+		layers = append(layers, layer)
+	}
+
+	add(&settingsLayer{name: LayerManaged, path: managedSettingsPath()})
+	for _, scope := range []string{ScopeLocal, ScopeProject} {
+		if target, err := ResolveScope(scope, dir); err == nil {
+			add(&settingsLayer{
+				name:    scope,
+				path:    target.Path,
+				ledger:  ledger.scope(target.Path),
+				profile: s.ScopeCurrent(target),
+			})
+		}
+	}
+	add(s.userLayer(ledger))
+
+	// Profiles injected by ccs exec, shell or env are marked in the
+	// environment
+	session := os.Getenv(SessionEnv)
+
+	explanations := make([]*Explanation, 0, len(keys))
+	for _, key := range keys {
+		e := &Explanation{Key: key}
+		for _, layer := range layers {
+			value, ok := layer.settings.Env[key]
+			if !ok {
+				continue
+			}
+			e.Values = append(e.Values, LayerValue{
+				Layer:   layer.name,
+				Source:  layer.path,
+				Value:   value,
+				Profile: layer.setBy(s, key, value),
+			})
+		}
+
+		if value, ok := os.LookupEnv(key); ok {
+			lv := LayerValue{Layer: LayerEnvironment, Value: value}
+			if s.profileSets(session, key, value) {
+				lv.Profile = session
+			}
+			e.Values = append(e.Values, lv)
+		}
+		explanations = append(explanations, e)
+	}
+
+	return explanations, errs
+}
+
+// userLayer returns the user settings layer Claude Code reads: an
+// isolated home's settings.json when CLAUDE_CONFIG_DIR points at one, and
+// the shared settings.json otherwise
+func (s *Store) userLayer(ledger *Ownership) *settingsLayer {
+	if home := os.Getenv(ClaudeConfigDirEnv); home != "" && isIsolatedHome(home) {
+		return &settingsLayer{
+			name:    LayerUser,
+			path:    filepath.Join(home, "settings.json"),
+			profile: filepath.Base(home),
+			direct:  true,
+		}
+	}
+	profile := s.Current
+	if p, ok := s.Profiles[profile]; ok && p.Isolated {
+		profile = ""
+	}
+	return &settingsLayer{name: LayerUser, path: getClaudeConfigPath(), ledger: ledger, profile: profile}
+}
+
+// profileSets reports whether profile name sets key to value. A secret
+// reference is taken to match, since resolving it may prompt or run a
+// command.
+func (s *Store) profileSets(name, key, value string) bool {
+	profile, ok := s.Profiles[name]
+	if !ok {
+		return false
+	}
+	own, ok := profile.Env[key]
+	return ok && (own == value || IsSecretRef(own))
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExplainFollowsPrecedence(t *testing.T) {
+	setupClaudeHome(t, "")
+	project := newGitRepo(t)
+	store := newSwitchStore(t)
+
+	managed := filepath.Join(t.TempDir(), "managed-settings.json")
+	if err := os.WriteFile(managed, []byte(`{"env": {"ANTHROPIC_BASE_URL": "https://gateway.corp"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	orig := managedSettingsPath
+	managedSettingsPath = func() string { return managed }
+	t.Cleanup(func() { managedSettingsPath = orig })
+
+	if _, err := store.Switch("first"); err != nil {
+		t.Fatal(err)
+	}
+	local, err := ResolveScope(ScopeLocal, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SwitchScope("second", local); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvModel, "from-shell")
+	t.Setenv(EnvBaseURL, "https://shell.example")
+
+	explanations, errs := store.Explain(project, []string{EnvModel, EnvBaseURL, EnvDefaultOpusModel})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	model := explanations[0]
+	if len(model.Values) != 3 {
+		t.Fatalf("model values = %+v", model.Values)
+	}
+	want := []LayerValue{
+		{Layer: LayerLocal, Source: local.Path, Value: "second-model", Profile: "second"},
+		{Layer: LayerUser, Source: getClaudeConfigPath(), Value: "first-model", Profile: "first"},
+		{Layer: LayerEnvironment, Value: "from-shell"},
+	}
+	for i, v := range model.Values {
+		if v != want[i] {
+			t.Errorf("model value %d = %+v, want %+v", i, v, want[i])
+		}
+	}
+
+	baseURL := explanations[1].Effective()
+	if baseURL == nil || baseURL.Layer != LayerManaged || baseURL.Value != "https://gateway.corp" || baseURL.Profile != "" {
+		t.Errorf("base URL = %+v, want the managed value", baseURL)
+	}
+
+	if explanations[2].Effective() != nil {
+		t.Errorf("unset key explained as %+v", explanations[2].Values)
+	}
+}
+
+func TestExplainHandEditedValueHasNoProfile(t *testing.T) {
+	setupClaudeHome(t, "")
+	store := newSwitchStore(t)
+	if _, err := store.Switch("first"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(getClaudeConfigPath(), []byte(`{"env": {"ANTHROPIC_MODEL": "hand-edited"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	explanations, _ := store.Explain(t.TempDir(), []string{EnvModel})
+	if v := explanations[0].Effective(); v == nil || v.Value != "hand-edited" || v.Profile != "" {
+		t.Errorf("hand-edited value explained as %+v", v)
+	}
+}
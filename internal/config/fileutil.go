@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// privateFileMode is used for files that may contain tokens
+	privateFileMode os.FileMode = 0600
+	// privateDirMode is used for directories ccs creates
+	privateDirMode os.FileMode = 0700
+)
+
+// existingMode returns the permissions of path, or fallback if it doesn't
+// exist yet
+func existingMode(path string, fallback os.FileMode) os.FileMode {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fallback
+	}
+	return info.Mode().Perm()
+}
+
+// writeFileAtomic writes data to a uniquely named temp file next to path
+// and renames it into place, so readers never see a half-written file and
+// concurrent writers never share a temp file. The file is synced, and the
+// directory both before the rename (so the temp file's entry is durable)
+// and after it, so the new content survives a crash.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	if err := syncDir(dir); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+
+	// Atomic rename
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	if err := syncDir(dir); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+
+	return nil
+}
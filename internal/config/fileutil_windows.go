@@ -0,0 +1,9 @@
+//go:build windows
+
+package config
+
+// syncDir is a no-op on Windows, where directories can't be synced and
+// renames are made durable by the file system
+func syncDir(dir string) error {
+	return nil
+}
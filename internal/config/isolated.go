@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sharedClaudeItems are symlinked from the shared Claude config directory
+// into every isolated home, so instructions, commands and agents stay the
+// same across profiles while history, credentials, MCP servers and project
+// trust are kept apart
+var sharedClaudeItems = []string{"CLAUDE.md", "commands", "agents", "skills"}
+
+// getHomesDir returns the directory holding isolated homes (~/.ccs/homes)
+func getHomesDir() string {
+	return filepath.Join(getCCSDir(), "homes")
+}
+
+// getIsolatedHome returns the Claude config directory of isolated profile name
+func getIsolatedHome(name string) string {
+	return filepath.Join(getHomesDir(), name)
+}
+
+// isIsolatedHome reports whether dir is inside ~/.ccs/homes
+func isIsolatedHome(dir string) bool {
+	rel, err := filepath.Rel(getHomesDir(), dir)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ClaudeConfigDir returns the CLAUDE_CONFIG_DIR Claude Code must be started
+// with for profile name, or "" if the profile uses the shared config
+func (s *Store) ClaudeConfigDir(name string) string {
+	if profile, ok := s.Profiles[name]; ok && profile.Isolated {
+		return getIsolatedHome(name)
+	}
+	return ""
+}
+
+// prepareIsolatedHome creates the isolated home of profile name if needed.
+// A new home's settings.json is seeded from the shared one, minus the env
+// values ccs applied there. Shared items are linked in every time, so ones
+// created since are picked up.
+func prepareIsolatedHome(name string) (string, error) {
+	home := getIsolatedHome(name)
+	if err := os.MkdirAll(home, privateDirMode); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", home, err)
+	}
+
+	settingsPath := filepath.Join(home, "settings.json")
+	if _, err := os.Stat(settingsPath); os.IsNotExist(err) {
+		settings, err := readClaudeSettingsFile(getClaudeConfigPath())
+		if err != nil {
+			return "", err
+		}
+		ledger, err := loadOwnership()
+		if err != nil {
+			return "", err
+		}
+		// Strip what the active shared profile put there; the ledger
+		// changes are discarded
+		for key := range ledger.Env {
+			ledger.release(settings.Env, key)
+		}
+		ledger.releaseSetting(settings, settingAPIKeyHelper)
+
+		if err := writeClaudeSettingsFile(settingsPath, settings); err != nil {
+			return "", err
+		}
+	}
+
+	var errs []error
+	shared := getClaudeDir()
+	for _, item := range sharedClaudeItems {
+		source := filepath.Join(shared, item)
+		target := filepath.Join(home, item)
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+		if _, err := os.Lstat(target); err == nil {
+			continue // Linked already, or replaced by the user
+		}
+		if err := os.Symlink(source, target); err != nil {
+			errs = append(errs, fmt.Errorf("failed to link %s: %w", item, err))
+		}
+	}
+
+	return home, errors.Join(errs...)
+}
+
+// stageIsolated applies the profile to its isolated home, staging the
+// home's settings.json and .claude.json in t. The env values ccs writes
+// there are tracked in the home's own ledger within ledger, so keys the
+// profile no longer has are removed on the next apply. With a non-empty
+// helper, the token is provided through apiKeyHelper as in the shared
+// config.
+func (p *Profile) stageIsolated(t *txn, home, helper string, ledger *Ownership) error {
+	env, err := p.ResolvedEnv()
+	if err != nil {
+		return err
+	}
+
+	settingsPath := filepath.Join(home, "settings.json")
+	settings, err := readClaudeSettingsFile(settingsPath)
+	if err != nil {
+		return err
+	}
+	_, tracked := ledger.Scoped[settingsPath]
+	owned := ledger.scope(settingsPath)
+
+	helpers := settings.stringSettings(settingAPIKeyHelper)
+	useHelper := helper != "" && p.hasToken()
+	if useHelper {
+		for _, key := range tokenKeys {
+			delete(env, key)
+		}
+		helpers[settingAPIKeyHelper] = helper
+	} else if strings.HasPrefix(helpers[settingAPIKeyHelper], apiKeyHelperCommand("")) {
+		delete(helpers, settingAPIKeyHelper)
+	}
+	settings.setStringSettings(settingAPIKeyHelper, helpers)
+
+	for key := range owned.Env {
+		if _, ok := env[key]; !ok {
+			owned.release(settings.Env, key)
+		}
+	}
+	if useHelper {
+		for _, key := range tokenKeys {
+			delete(settings.Env, key)
+		}
+	}
+	for key, value := range env {
+		if !tracked && settings.Env[key] == value {
+			// Written by ccs before homes had a ledger
+			owned.Env[key] = &OwnershipEntry{Applied: value}
+		}
+		owned.claim(settings.Env, key, value)
+	}
+	if err := stageClaudeSettings(t, settingsPath, settings); err != nil {
+		return err
+	}
+
+	// Skip Claude Code's first-time setup in the new home too
+	claudeJSONPath := filepath.Join(home, ".claude.json")
+	doc, err := readClaudeJSONFile(claudeJSONPath)
+	if err != nil {
+		return err
+	}
+	if changed, _ := setOnboardingFlag(doc); changed {
+		return stageClaudeJSONFile(t, claudeJSONPath, doc)
+	}
+	return nil
+}
+
+// SyncIsolatedHome prepares the isolated home of profile name and writes
+// the profile into it, returning the home. It is used by ccs exec, which
+// runs Claude Code in the home without making the profile active.
+func (s *Store) SyncIsolatedHome(name string) (string, error) {
+	profile, err := s.GetProfile(name)
+	if err != nil {
+		return "", err
+	}
+	if !profile.Isolated {
+		return "", fmt.Errorf("profile '%s' is not isolated", name)
+	}
+
+	mode, err := loadApplyMode()
+	if err != nil {
+		return "", err
+	}
+	helper := ""
+	if mode == ApplyModeAPIKeyHelper {
+		helper = apiKeyHelperCommand(name)
+	}
+
+	home, linkErr := prepareIsolatedHome(name)
+	if home == "" {
+		return "", linkErr
+	}
+
+	ledger, err := loadOwnership()
+	if err != nil {
+		return "", err
+	}
+
+	t := newTxn()
+	if err := profile.stageIsolated(t, home, helper, ledger); err != nil {
+		return "", err
+	}
+	if err := ledger.stage(t); err != nil {
+		return "", err
+	}
+	if err := t.commit(); err != nil {
+		return "", err
+	}
+	return home, linkErr
+}
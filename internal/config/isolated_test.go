@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSwitchToIsolatedProfile(t *testing.T) {
+	home := setupClaudeHome(t, `{"model": "opus", "env": {"KEEP_ME": "yes"}}`)
+	if err := os.WriteFile(filepath.Join(home, ".claude", "CLAUDE.md"), []byte("shared"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newSwitchStore(t)
+	store.Profiles["second"].Isolated = true
+
+	if _, err := store.Switch("first"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := store.Switch("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.LinkErr != nil {
+		t.Fatal(result.LinkErr)
+	}
+	if result.ConfigDir != filepath.Join(home, ".ccs", "homes", "second") {
+		t.Fatalf("ConfigDir = %s", result.ConfigDir)
+	}
+
+	// The shared settings lose the first profile and don't get the second
+	env := readSettingsMap(t)["env"].(map[string]any)
+	if _, ok := env[EnvModel]; ok || env["KEEP_ME"] != "yes" {
+		t.Errorf("shared settings.json env = %v", env)
+	}
+
+	// The isolated home is seeded from the shared settings plus the profile
+	data, err := os.ReadFile(filepath.Join(result.ConfigDir, "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var isolated ClaudeSettings
+	if err := isolated.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	if isolated.Env[EnvAuthToken] != "sk-second" || isolated.Env["KEEP_ME"] != "yes" {
+		t.Errorf("isolated settings.json env = %v", isolated.Env)
+	}
+	if target, err := os.Readlink(filepath.Join(result.ConfigDir, "CLAUDE.md")); err != nil || target != filepath.Join(home, ".claude", "CLAUDE.md") {
+		t.Errorf("CLAUDE.md not linked: %s %v", target, err)
+	}
+
+	// Paths set up by ccs in CLAUDE_CONFIG_DIR don't redirect the shared config
+	t.Setenv(ClaudeConfigDirEnv, result.ConfigDir)
+	if getClaudeConfigPath() != filepath.Join(home, ".claude", "settings.json") {
+		t.Errorf("shared settings path followed an isolated home: %s", getClaudeConfigPath())
+	}
+	t.Setenv(ClaudeConfigDirEnv, filepath.Join(home, "custom"))
+	if getClaudeJSONPath() != filepath.Join(home, "custom", ".claude.json") {
+		t.Errorf("user CLAUDE_CONFIG_DIR ignored: %s", getClaudeJSONPath())
+	}
+}
+
+func TestIsolatedHomeDropsRemovedKeys(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"KEEP_ME": "yes"}}`)
+	store := newSwitchStore(t)
+	store.Profiles["second"].Isolated = true
+	if _, err := store.UpdateProfile("second", map[string]string{EnvBaseURL: "https://old.example.com", "KEEP_ME": "override"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	home, err := store.SyncIsolatedHome("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readEnv := func() map[string]string {
+		t.Helper()
+		settings, err := readClaudeSettingsFile(filepath.Join(home, "settings.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return settings.Env
+	}
+	if env := readEnv(); env[EnvBaseURL] != "https://old.example.com" || env["KEEP_ME"] != "override" {
+		t.Fatalf("profile not applied: %v", env)
+	}
+
+	if _, err := store.UpdateProfile("second", nil, []string{EnvBaseURL, "KEEP_ME"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.SyncIsolatedHome("second"); err != nil {
+		t.Fatal(err)
+	}
+	env := readEnv()
+	if _, ok := env[EnvBaseURL]; ok {
+		t.Errorf("removed base URL still in the isolated home: %v", env)
+	}
+	if env["KEEP_ME"] != "yes" || env[EnvAuthToken] != "sk-second" {
+		t.Errorf("isolated env = %v", env)
+	}
+}
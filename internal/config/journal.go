@@ -0,0 +1,352 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxJournalEntries is how many operations ccs remembers for undo
+const maxJournalEntries = 50
+
+// journalKind prefixes journal entry files, which are named like backups:
+// op-<id>.json
+const journalKind = "op"
+
+// JournalEntry records one state-changing command and what the files it
+// changed looked like before it ran
+type JournalEntry struct {
+	ID      string        `json:"-"`
+	Time    time.Time     `json:"time"`
+	Command string        `json:"command"`
+	Files   []JournalFile `json:"files"`
+}
+
+// JournalFile is the before-snapshot of one file changed by a command.
+// Secrets are redacted from it, or sealed in profiles.json, as in backups.
+type JournalFile struct {
+	Kind string `json:"kind"`
+	// Path is set for project and local settings files
+	Path    string `json:"path,omitempty"`
+	Existed bool   `json:"existed"`
+	Before  []byte `json:"before,omitempty"`
+	// AfterHash identifies the content the command left behind, so undo
+	// can tell whether the file was changed again since
+	AfterHash string `json:"afterHash"`
+}
+
+// Kinds returns the kinds of file the entry changed
+func (e *JournalEntry) Kinds() []string {
+	kinds := make([]string, len(e.Files))
+	for i, f := range e.Files {
+		kinds[i] = f.Kind
+	}
+	return kinds
+}
+
+// livePath returns the path of the file the snapshot was taken from
+func (f *JournalFile) livePath() string {
+	if f.Path != "" {
+		return f.Path
+	}
+	return livePath(f.Kind, nil)
+}
+
+// stateFile is a live file whose changes are journaled
+type stateFile struct {
+	kind string
+	path string
+}
+
+// globalStateFiles returns the files every command may change
+func globalStateFiles() []stateFile {
+	files := make([]stateFile, len(BackupKinds))
+	for i, kind := range BackupKinds {
+		files[i] = stateFile{kind: kind, path: backupSources[kind]()}
+	}
+	return files
+}
+
+// getJournalDir returns the operation journal directory (~/.ccs/journal)
+func getJournalDir() string {
+	return filepath.Join(getCCSDir(), "journal")
+}
+
+// readState reads the live content of files, keyed by path
+func readState(files []stateFile) (map[string][]byte, error) {
+	state := make(map[string][]byte)
+	for _, f := range files {
+		data, err := os.ReadFile(f.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		state[f.path] = data
+	}
+	return state, nil
+}
+
+// hashContent returns a hash of a file's content, or "" if it doesn't exist
+func hashContent(data []byte, exists bool) string {
+	if !exists {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Record runs op and, if it succeeds and changed any state file, adds an
+// entry for command to the operation journal so it can be undone
+func Record(command string, op func() error) error {
+	return record(command, globalStateFiles(), op)
+}
+
+// RecordScope is Record for a command that also changes the settings file
+// of target
+func RecordScope(command string, target *ScopeTarget, op func() error) error {
+	files := globalStateFiles()
+	if target.Scope != ScopeUser {
+		files = append(files, stateFile{kind: target.backupKind(), path: target.Path})
+	}
+	return record(command, files, op)
+}
+
+// record runs op, journaling the changes it makes to files
+func record(command string, files []stateFile, op func() error) error {
+	before, err := readState(files)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot state: %w", err)
+	}
+
+	if err := op(); err != nil {
+		return err
+	}
+
+	after, err := readState(files)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot state: %w", err)
+	}
+
+	isSecret := SecretKeyMatcher()
+	entry := &JournalEntry{Time: time.Now(), Command: command}
+	for _, f := range files {
+		oldData, existed := before[f.path]
+		newData, exists := after[f.path]
+		if existed == exists && string(oldData) == string(newData) {
+			continue
+		}
+		if redacted, _, err := redactBackupData(f.kind, oldData, isSecret); err == nil {
+			oldData = redacted
+		}
+		jf := JournalFile{
+			Kind:      f.kind,
+			Existed:   existed,
+			Before:    oldData,
+			AfterHash: hashContent(newData, exists),
+		}
+		if _, global := backupSources[f.kind]; !global {
+			jf.Path = f.path
+		}
+		entry.Files = append(entry.Files, jf)
+	}
+
+	if len(entry.Files) == 0 {
+		return nil
+	}
+
+	if err := appendJournal(entry); err != nil {
+		return fmt.Errorf("failed to record operation: %w", err)
+	}
+	return nil
+}
+
+// appendJournal writes entry to the journal under a new id and drops the
+// oldest entries beyond maxJournalEntries
+func appendJournal(entry *JournalEntry) error {
+	dir := getJournalDir()
+	if err := os.MkdirAll(dir, privateDirMode); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if entry.ID, err = createBackupFile(dir, journalKind, data); err != nil {
+		return err
+	}
+
+	entries, err := History()
+	if err != nil {
+		return nil
+	}
+	for _, old := range entries[min(len(entries), maxJournalEntries):] {
+		os.Remove(backupFilePath(dir, journalKind, old.ID))
+	}
+	return nil
+}
+
+// scrubJournal redacts the secrets left in journal snapshots, returning the
+// number of entries changed
+func scrubJournal(isSecret func(string) bool) (int, error) {
+	entries, err := History()
+	if err != nil {
+		return 0, err
+	}
+
+	scrubbed := 0
+	for _, entry := range entries {
+		changed := false
+		for i := range entry.Files {
+			f := &entry.Files[i]
+			redacted, ok, err := redactBackupData(f.Kind, f.Before, isSecret)
+			if err != nil || !ok {
+				continue
+			}
+			f.Before = redacted
+			changed = true
+		}
+		if !changed {
+			continue
+		}
+
+		if err := writeJournalEntry(entry); err != nil {
+			return scrubbed, err
+		}
+		scrubbed++
+	}
+	return scrubbed, nil
+}
+
+// writeJournalEntry rewrites an existing journal entry
+func writeJournalEntry(entry *JournalEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(backupFilePath(getJournalDir(), journalKind, entry.ID), data, privateFileMode)
+}
+
+// History returns the journal, most recent operation first
+func History() ([]*JournalEntry, error) {
+	dir := getJournalDir()
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var entries []*JournalEntry
+	for _, de := range dirEntries {
+		m := backupNamePattern.FindStringSubmatch(de.Name())
+		if de.IsDir() || m == nil || m[1] != journalKind {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, de.Name()))
+		if err != nil {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		entry.ID = m[2]
+		entries = append(entries, &entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID > entries[j].ID
+	})
+
+	return entries, nil
+}
+
+// Undo reverts the most recent journal entry and removes it from the
+// journal, so calling Undo again walks further back. Unless force is set,
+// it refuses if any of the entry's files were changed after it ran.
+// Redacted secrets are refilled as on restore, and the live files are
+// backed up first.
+func Undo(force bool) (*JournalEntry, error) {
+	entries, err := History()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	entry := entries[0]
+
+	files := make([]stateFile, len(entry.Files))
+	paths := make(map[string]string)
+	for i := range entry.Files {
+		f := &entry.Files[i]
+		files[i] = stateFile{kind: f.Kind, path: f.livePath()}
+		if f.Path != "" {
+			paths[f.Kind] = f.Path
+		}
+	}
+
+	current, err := readState(files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current state: %w", err)
+	}
+
+	if !force {
+		for i, f := range files {
+			data, exists := current[f.path]
+			if hashContent(data, exists) != entry.Files[i].AfterHash {
+				return nil, fmt.Errorf("%s was changed after '%s'; use --force to undo anyway", f.kind, entry.Command)
+			}
+		}
+	}
+
+	index := newSecretIndex()
+	for _, f := range entry.Files {
+		if f.Kind == BackupProfiles && f.Existed {
+			index.addStoreData(f.Before)
+		}
+	}
+
+	t := newTxn()
+	var removals []string
+	for i, f := range entry.Files {
+		path := files[i].path
+		if !f.Existed {
+			removals = append(removals, path)
+			continue
+		}
+		data, err := refillBackupData(f.Kind, f.Before, index)
+		if err != nil {
+			return nil, fmt.Errorf("cannot undo %s: %w", f.Kind, err)
+		}
+		t.stage(path, data, liveFileMode(f.Kind, path))
+	}
+
+	if err := backupFilesAt(paths, entry.Kinds()...); err != nil {
+		return nil, fmt.Errorf("failed to backup current files: %w", err)
+	}
+	if err := t.commit(); err != nil {
+		return nil, err
+	}
+	for _, path := range removals {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	if err := os.Remove(backupFilePath(getJournalDir(), journalKind, entry.ID)); err != nil {
+		return nil, fmt.Errorf("failed to update journal: %w", err)
+	}
+
+	return entry, nil
+}
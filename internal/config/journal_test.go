@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUndoWalksBackThroughJournal(t *testing.T) {
+	original := `{"env": {"ANTHROPIC_MODEL": "hand-set"}}`
+	setupClaudeHome(t, original)
+	store := newSwitchStore(t)
+
+	if err := Record("add", store.Save); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"first", "second"} {
+		name := name
+		if err := Record("use "+name, func() error {
+			_, err := store.Switch(name)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := History()
+	if err != nil || len(entries) != 3 || entries[0].Command != "use second" {
+		t.Fatalf("unexpected history: %v %v", entries, err)
+	}
+
+	for _, want := range []string{"use second", "use first", "add"} {
+		entry, err := Undo(false)
+		if err != nil {
+			t.Fatalf("undo %s: %v", want, err)
+		}
+		if entry.Command != want {
+			t.Errorf("undid %q, want %q", entry.Command, want)
+		}
+	}
+
+	data, _ := os.ReadFile(getClaudeConfigPath())
+	if string(data) != original {
+		t.Errorf("settings.json not restored:\ngot  %s\nwant %s", data, original)
+	}
+	if _, err := os.Stat(getProfilesPath()); !os.IsNotExist(err) {
+		t.Errorf("profiles.json should be gone after undoing its creation: %v", err)
+	}
+	if _, err := Undo(false); err == nil {
+		t.Error("expected nothing to undo")
+	}
+}
+
+func TestUndoRefusesAfterLaterChanges(t *testing.T) {
+	setupClaudeHome(t, `{"env": {}}`)
+	store := newSwitchStore(t)
+
+	if err := Record("use first", func() error {
+		_, err := store.Switch("first")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(getClaudeConfigPath(), []byte(`{"env": {"X": "y"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Undo(false); err == nil {
+		t.Fatal("expected undo to refuse after settings.json changed")
+	}
+	if _, err := Undo(true); err != nil {
+		t.Fatalf("forced undo: %v", err)
+	}
+}
+
+func TestJournalSnapshotsAreRedacted(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_AUTH_TOKEN": "sk-hand-set-token"}}`)
+	store := newSwitchStore(t)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"second", "first"} {
+		name := name
+		if err := Record("use "+name, func() error {
+			_, err := store.Switch(name)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, _ := History()
+	for _, entry := range entries {
+		for _, f := range entry.Files {
+			if strings.Contains(string(f.Before), "sk-second") || strings.Contains(string(f.Before), "sk-hand-set-token") {
+				t.Errorf("secret in %q snapshot of %s: %s", entry.Command, f.Kind, f.Before)
+			}
+		}
+	}
+
+	if _, err := Undo(false); err != nil {
+		t.Fatal(err)
+	}
+	env := readSettingsMap(t)["env"].(map[string]any)
+	if env[EnvAuthToken] != "sk-second" {
+		t.Errorf("token not refilled on undo: %v", env)
+	}
+}
+
+func TestUndoRemoveUnsealsProfiles(t *testing.T) {
+	setupClaudeHome(t, "")
+	store := newSwitchStore(t)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record("rm second", func() error {
+		if err := store.RemoveProfile("second"); err != nil {
+			return err
+		}
+		return store.Save()
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := History()
+	if strings.Contains(string(entries[0].Files[0].Before), "sk-second") {
+		t.Errorf("plaintext token in journaled profiles.json: %s", entries[0].Files[0].Before)
+	}
+
+	if _, err := Undo(false); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := restored.GetProfile("second"); err != nil || profile.Env[EnvAuthToken] != "sk-second" {
+		t.Errorf("second not restored with its token: %v %v", profile, err)
+	}
+}
+
+func TestScrubBackupsCoversJournal(t *testing.T) {
+	setupClaudeHome(t, "")
+	entry := &JournalEntry{Command: "use old", Files: []JournalFile{{
+		Kind:    BackupSettings,
+		Existed: true,
+		Before:  []byte(`{"env": {"ANTHROPIC_AUTH_TOKEN": "sk-old-token"}}`),
+	}, {
+		Kind:    BackupProfiles,
+		Existed: true,
+		Before:  []byte(`{"current": "old", "profiles": {"old": {"env": {"ANTHROPIC_AUTH_TOKEN": "sk-old-token"}}}}`),
+	}}}
+	if err := appendJournal(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if scrubbed, err := ScrubBackups(); err != nil || scrubbed != 1 {
+		t.Fatalf("ScrubBackups = %d, %v", scrubbed, err)
+	}
+	entries, _ := History()
+	if len(entries) != 1 {
+		t.Fatalf("unexpected journal: %+v", entries)
+	}
+	for _, f := range entries[0].Files {
+		if strings.Contains(string(f.Before), "sk-old-token") {
+			t.Errorf("secret left in journaled %s: %s", f.Kind, f.Before)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrLockTimeout is returned when another ccs process holds the state lock
+// for longer than the configured timeout
+var ErrLockTimeout = errors.New("timed out waiting for the ccs lock")
+
+// lockPollInterval is how often a busy lock is retried
+const lockPollInterval = 50 * time.Millisecond
+
+var (
+	lockMu    sync.Mutex
+	lockDepth int
+	lockFile  *os.File
+)
+
+// getLockPath returns the path to the ccs lock file
+func getLockPath() string {
+	return filepath.Join(getCCSDir(), "lock")
+}
+
+// Lock takes the exclusive ccs state lock, waiting up to the configured
+// timeout for other ccs processes to release it. It is reentrant within a
+// process. Call the returned function to release the lock.
+func Lock() (func(), error) {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockDepth > 0 {
+		lockDepth++
+		return unlock, nil
+	}
+
+	timeout := time.Duration(DefaultConfig().LockTimeoutSeconds) * time.Second
+	if cfg, err := LoadConfig(); err == nil {
+		timeout = time.Duration(cfg.LockTimeoutSeconds) * time.Second
+	}
+
+	path := getLockPath()
+	if err := os.MkdirAll(filepath.Dir(path), privateDirMode); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, privateFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w: another ccs process has held %s for over %s", ErrLockTimeout, path, timeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	lockFile = f
+	lockDepth = 1
+	return unlock, nil
+}
+
+// unlock releases one level of the state lock
+func unlock() {
+	lockMu.Lock()
+	defer lockMu.Unlock()
+
+	if lockDepth == 0 {
+		return
+	}
+	lockDepth--
+	if lockDepth == 0 {
+		_ = unlockFile(lockFile)
+		lockFile.Close()
+		lockFile = nil
+	}
+}
+
+// WithLock runs fn while holding the ccs state lock
+func WithLock(fn func() error) error {
+	release, err := Lock()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
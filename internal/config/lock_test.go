@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// TestLockHelperProcess holds the lock from a separate process so the
+// timeout path can be exercised; it is a no-op unless run as a helper.
+func TestLockHelperProcess(t *testing.T) {
+	if os.Getenv("CCS_LOCK_HELPER") != "1" {
+		return
+	}
+	release, err := Lock()
+	if err != nil {
+		os.Exit(2)
+	}
+	defer release()
+	os.Stdout.WriteString("locked\n")
+	time.Sleep(3 * time.Second)
+}
+
+func TestLockTimesOutWhenHeldElsewhere(t *testing.T) {
+	home := setupClaudeHome(t, "")
+	if err := os.MkdirAll(getCCSDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(getConfigPath(), []byte(`{"lockTimeoutSeconds": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	helper := exec.Command(os.Args[0], "-test.run=TestLockHelperProcess")
+	helper.Env = append(os.Environ(), "CCS_LOCK_HELPER=1", "HOME="+home)
+	stdout, err := helper.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := helper.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		helper.Process.Kill()
+		helper.Wait()
+	}()
+
+	buf := make([]byte, len("locked\n"))
+	if _, err := stdout.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Lock(); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+}
+
+func TestLockIsReentrant(t *testing.T) {
+	setupClaudeHome(t, "")
+
+	outer, err := Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner()
+	outer()
+
+	again, err := Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again()
+}
@@ -0,0 +1,19 @@
+package config
+
+// MaskValue masks sensitive values for display. Secret references and
+// redacted placeholders are shown as they are, since they hold no secret
+// themselves.
+func MaskValue(key, value string) string {
+	if IsSecretRef(value) || IsRedacted(value) {
+		return value
+	}
+
+	// Mask API tokens
+	if IsSecretKey(key) {
+		if len(value) <= 8 {
+			return "***"
+		}
+		return value[:4] + "***" + value[len(value)-4:]
+	}
+	return value
+}
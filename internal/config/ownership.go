@@ -0,0 +1,157 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// OwnershipEntry records what ccs found in settings.json before it first
+// took over an env key, and the value it last wrote there
+type OwnershipEntry struct {
+	Existed  bool   `json:"existed"`
+	Original string `json:"original,omitempty"`
+	Applied  string `json:"applied"`
+}
+
+// Ownership is the ledger of env keys, and top-level string settings such
+// as apiKeyHelper, that ccs currently owns in settings.json
+type Ownership struct {
+	Env      map[string]*OwnershipEntry `json:"env"`
+	Settings map[string]*OwnershipEntry `json:"settings,omitempty"`
+	// Scoped holds a ledger for each project or local settings file ccs
+	// has applied a profile to, keyed by its path
+	Scoped map[string]*Ownership `json:"scoped,omitempty"`
+}
+
+// newOwnership returns an empty ledger
+func newOwnership() *Ownership {
+	return &Ownership{
+		Env:      make(map[string]*OwnershipEntry),
+		Settings: make(map[string]*OwnershipEntry),
+	}
+}
+
+// scope returns the ledger for the project or local settings file at path
+func (o *Ownership) scope(path string) *Ownership {
+	if o.Scoped == nil {
+		o.Scoped = make(map[string]*Ownership)
+	}
+	ledger, ok := o.Scoped[path]
+	if !ok {
+		ledger = newOwnership()
+		o.Scoped[path] = ledger
+	}
+	if ledger.Env == nil {
+		ledger.Env = make(map[string]*OwnershipEntry)
+	}
+	if ledger.Settings == nil {
+		ledger.Settings = make(map[string]*OwnershipEntry)
+	}
+	return ledger
+}
+
+// loadOwnership reads the ownership ledger from ~/.ccs
+func loadOwnership() (*Ownership, error) {
+	ledger := newOwnership()
+
+	data, err := os.ReadFile(getOwnershipPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ledger, nil
+		}
+		return nil, fmt.Errorf("failed to read ownership ledger: %w", err)
+	}
+
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("failed to parse ownership ledger: %w", err)
+	}
+
+	if ledger.Env == nil {
+		ledger.Env = make(map[string]*OwnershipEntry)
+	}
+	if ledger.Settings == nil {
+		ledger.Settings = make(map[string]*OwnershipEntry)
+	}
+
+	return ledger, nil
+}
+
+// stage queues the ownership ledger to be written by t
+func (o *Ownership) stage(t *txn) error {
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ownership ledger: %w", err)
+	}
+
+	t.stage(getOwnershipPath(), data, privateFileMode)
+	return nil
+}
+
+// claim sets key to value in env, recording the value it replaces the
+// first time ccs takes the key over
+func (o *Ownership) claim(env map[string]string, key, value string) {
+	claimEntry(o.Env, env, key, value)
+}
+
+// release gives key back in env: the original value is restored, or the
+// key is deleted if ccs created it. If the key was edited by hand since ccs
+// wrote it, the hand-edited value is left alone.
+func (o *Ownership) release(env map[string]string, key string) {
+	if _, owned := o.Env[key]; !owned {
+		// Applied before the ledger existed; fall back to deleting
+		delete(env, key)
+		return
+	}
+	releaseEntry(o.Env, env, key)
+}
+
+// claimSetting sets the top-level string setting key, recording the value
+// it replaces the first time ccs takes it over
+func (o *Ownership) claimSetting(settings *ClaudeSettings, key, value string) {
+	values := settings.stringSettings(key)
+	claimEntry(o.Settings, values, key, value)
+	settings.setStringSettings(key, values)
+}
+
+// releaseSetting gives the top-level setting key back, like release. A
+// setting ccs never claimed is left alone.
+func (o *Ownership) releaseSetting(settings *ClaudeSettings, key string) {
+	values := settings.stringSettings(key)
+	releaseEntry(o.Settings, values, key)
+	settings.setStringSettings(key, values)
+}
+
+// claimEntry sets key to value in values, recording in entries the value
+// it replaces if key isn't owned yet
+func claimEntry(entries map[string]*OwnershipEntry, values map[string]string, key, value string) {
+	entry, owned := entries[key]
+	if !owned {
+		original, existed := values[key]
+		entry = &OwnershipEntry{Existed: existed, Original: original}
+		entries[key] = entry
+	}
+	entry.Applied = value
+	values[key] = value
+}
+
+// releaseEntry restores the value key held in values before it was claimed
+// in entries, unless it was edited since
+func releaseEntry(entries map[string]*OwnershipEntry, values map[string]string, key string) {
+	entry, owned := entries[key]
+	if !owned {
+		return
+	}
+	delete(entries, key)
+
+	current, present := values[key]
+	if !present || current != entry.Applied {
+		return
+	}
+
+	if entry.Existed {
+		values[key] = entry.Original
+	} else {
+		delete(values, key)
+	}
+}
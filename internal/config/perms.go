@@ -0,0 +1,69 @@
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// PermissionIssue is a file or directory that may hold tokens and is
+// accessible to users other than its owner
+type PermissionIssue struct {
+	Path string
+	Mode os.FileMode
+	Want os.FileMode
+}
+
+// CheckPermissions lists ccs state and Claude settings files that group or
+// other users can access. Permission bits aren't meaningful on Windows, so
+// nothing is reported there.
+func CheckPermissions() []PermissionIssue {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	var issues []PermissionIssue
+	check := func(path string, info fs.FileInfo) {
+		mode := info.Mode().Perm()
+		if mode&0077 != 0 {
+			issues = append(issues, PermissionIssue{Path: path, Mode: mode, Want: mode &^ 0077})
+		}
+	}
+
+	// Isolated homes are managed by Claude Code, so only the directories
+	// and the files ccs writes there are checked
+	var claudeFiles []string
+	_ = filepath.Walk(getCCSDir(), func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() || info.Mode().IsRegular() {
+			check(path, info)
+		}
+		if info.IsDir() && filepath.Dir(path) == getHomesDir() {
+			claudeFiles = append(claudeFiles, filepath.Join(path, "settings.json"), filepath.Join(path, ".claude.json"))
+			return filepath.SkipDir
+		}
+		return nil
+	})
+
+	claudeFiles = append(claudeFiles, getClaudeConfigPath(), getClaudeJSONPath())
+	for _, path := range claudeFiles {
+		if info, err := os.Stat(path); err == nil {
+			check(path, info)
+		}
+	}
+
+	return issues
+}
+
+// FixPermissions removes group and other access from the given paths
+func FixPermissions(issues []PermissionIssue) error {
+	for _, issue := range issues {
+		if err := os.Chmod(issue.Path, issue.Want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSecretFilesArePrivate(t *testing.T) {
+	setupClaudeHome(t, `{"env": {}}`)
+	if err := os.Chmod(getClaudeConfigPath(), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newSwitchStore(t)
+	if _, err := store.Switch("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{getProfilesPath(), getOwnershipPath()} {
+		if mode := existingMode(path, 0); mode != privateFileMode {
+			t.Errorf("%s: mode %v, want %v", path, mode, privateFileMode)
+		}
+	}
+	if mode := existingMode(getCCSDir(), 0); mode != privateDirMode {
+		t.Errorf("%s: mode %v, want %v", getCCSDir(), mode, privateDirMode)
+	}
+
+	// Claude Code's own file keeps the mode it had
+	if mode := existingMode(getClaudeConfigPath(), 0); mode != 0640 {
+		t.Errorf("settings.json: mode %v, want 0640", mode)
+	}
+
+	backups, err := ListBackups()
+	if err != nil || len(backups) == 0 {
+		t.Fatalf("ListBackups: %v %v", backups, err)
+	}
+	for _, path := range backups[0].Files {
+		if mode := existingMode(path, 0); mode != privateFileMode {
+			t.Errorf("%s: mode %v, want %v", path, mode, privateFileMode)
+		}
+	}
+
+	issues := CheckPermissions()
+	if len(issues) != 1 || issues[0].Path != getClaudeConfigPath() {
+		t.Fatalf("unexpected permission issues: %+v", issues)
+	}
+	if err := FixPermissions(issues); err != nil {
+		t.Fatal(err)
+	}
+	if issues := CheckPermissions(); len(issues) != 0 {
+		t.Errorf("issues remain after fix: %+v", issues)
+	}
+}
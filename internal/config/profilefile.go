@@ -0,0 +1,211 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ValidateEnvKey checks that key can be used as an environment variable
+func ValidateEnvKey(key string) error {
+	if !envNamePattern.MatchString(key) {
+		return fmt.Errorf("invalid variable name '%s'", key)
+	}
+	return nil
+}
+
+// ParseEnvAssignment splits a KEY=VALUE argument
+func ParseEnvAssignment(arg string) (string, string, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok {
+		return "", "", fmt.Errorf("expected KEY=VALUE, got '%s'", arg)
+	}
+	if err := ValidateEnvKey(key); err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+// ParseProfileData reads env values from a profile file: JSON, either
+// {"env": {...}} as in profiles.json and settings.json or a flat object of
+// strings, or otherwise .env lines of KEY=VALUE
+func ParseProfileData(data []byte) (map[string]string, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseProfileJSON(trimmed)
+	}
+	return parseDotenv(data)
+}
+
+// parseProfileJSON reads env values from a JSON profile file
+func parseProfileJSON(data []byte) (map[string]string, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if raw, ok := doc["env"]; ok {
+		doc = nil
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return nil, fmt.Errorf("env must be an object: %w", err)
+		}
+	}
+
+	env := make(map[string]string, len(doc))
+	for key, raw := range doc {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("%s: value must be a string", key)
+		}
+		if err := ValidateEnvKey(key); err != nil {
+			return nil, err
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// parseDotenv reads KEY=VALUE lines. Blank lines and # comments are
+// skipped, an "export " prefix is allowed, single-quoted values are taken
+// literally and double-quoted ones may use \n, \t, \" and \\ escapes.
+func parseDotenv(data []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, err := ParseEnvAssignment(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if value, err = unquoteDotenv(strings.TrimSpace(value)); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		env[key] = value
+	}
+	return env, scanner.Err()
+}
+
+// unquoteDotenv removes the quotes around a .env value
+func unquoteDotenv(value string) (string, error) {
+	if len(value) < 2 || (value[0] != '"' && value[0] != '\'') {
+		return value, nil
+	}
+	quote := value[0]
+	if value[len(value)-1] != quote {
+		return "", fmt.Errorf("unterminated quoted value")
+	}
+	value = value[1 : len(value)-1]
+	if quote == '\'' {
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i == len(value)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// UpdateProfile sets and removes env values of profile name. If it is the
+// active profile at user scope, settings.json is updated in the same
+// transaction, and applied is true. Isolated profiles and profiles active
+// at project or local scope only take the change on the next ccs use.
+func (s *Store) UpdateProfile(name string, set map[string]string, unset []string) (applied bool, err error) {
+	profile, err := s.GetProfile(name)
+	if err != nil {
+		return false, err
+	}
+	for _, key := range unset {
+		if _, ok := profile.Env[key]; !ok {
+			return false, fmt.Errorf("profile '%s' has no %s", name, key)
+		}
+	}
+
+	updated := NewProfile()
+	updated.Isolated = profile.Isolated
+	for key, value := range profile.Env {
+		updated.Env[key] = value
+	}
+	for key, value := range set {
+		updated.Env[key] = value
+	}
+	for _, key := range unset {
+		delete(updated.Env, key)
+	}
+	if len(updated.Env) == 0 && !updated.Isolated {
+		return false, fmt.Errorf("profile '%s' cannot be left empty", name)
+	}
+
+	if s.Current != name || profile.Isolated {
+		s.Profiles[name] = updated
+		if err := s.Save(); err != nil {
+			s.Profiles[name] = profile
+			return false, err
+		}
+		return false, nil
+	}
+
+	mode, err := loadApplyMode()
+	if err != nil {
+		return false, err
+	}
+	helper := ""
+	if mode == ApplyModeAPIKeyHelper {
+		helper = apiKeyHelperCommand(name)
+	}
+
+	claudePath := getClaudeConfigPath()
+	settings, err := readClaudeSettingsFile(claudePath)
+	if err != nil {
+		return false, err
+	}
+	ledger, err := loadOwnership()
+	if err != nil {
+		return false, err
+	}
+
+	profile.clearFrom(settings, ledger)
+	if err := updated.applyTo(settings, ledger, helper); err != nil {
+		return false, err
+	}
+
+	if err := backupFiles(BackupSettings, BackupOwnership, BackupProfiles); err != nil {
+		return false, fmt.Errorf("failed to backup current files: %w", err)
+	}
+
+	s.Profiles[name] = updated
+	t := newTxn()
+	err = stageClaudeSettings(t, claudePath, settings)
+	if err == nil {
+		err = ledger.stage(t)
+	}
+	if err == nil {
+		err = s.stage(t)
+	}
+	if err == nil {
+		err = t.commit()
+	}
+	if err != nil {
+		s.Profiles[name] = profile
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestParseProfileData(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string]string
+	}{
+		{"nested json", `{"env": {"API_TIMEOUT_MS": "600000"}, "model": "x"}`, map[string]string{"API_TIMEOUT_MS": "600000"}},
+		{"flat json", `{"HTTPS_PROXY": "http://proxy"}`, map[string]string{"HTTPS_PROXY": "http://proxy"}},
+		{"dotenv", "# comment\n\nexport A=1\nB='$literal'\nC=\"line\\nbreak \\\"q\\\"\"\nD=\n", map[string]string{
+			"A": "1",
+			"B": "$literal",
+			"C": "line\nbreak \"q\"",
+			"D": "",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProfileData([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("%s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+
+	for _, bad := range []string{`{"env": {"A": 1}}`, "NOVALUE", "1A=x", "A=\"open"} {
+		if _, err := ParseProfileData([]byte(bad)); err == nil {
+			t.Errorf("ParseProfileData(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestUpdateActiveProfileAppliesChange(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"HTTPS_PROXY": "http://mine"}}`)
+	store := newSwitchStore(t)
+	if _, err := store.Switch("second"); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := store.UpdateProfile("second", map[string]string{"HTTPS_PROXY": "http://corp"}, []string{EnvAuthToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !applied {
+		t.Error("change to the active profile not applied")
+	}
+	env := readSettingsMap(t)["env"].(map[string]any)
+	if env["HTTPS_PROXY"] != "http://corp" {
+		t.Errorf("HTTPS_PROXY = %v", env["HTTPS_PROXY"])
+	}
+	if _, ok := env[EnvAuthToken]; ok {
+		t.Errorf("unset token still in settings: %v", env)
+	}
+
+	// The value the profile replaced comes back when it is unset again
+	if _, err := store.UpdateProfile("second", nil, []string{"HTTPS_PROXY"}); err != nil {
+		t.Fatal(err)
+	}
+	if env := readSettingsMap(t)["env"].(map[string]any); env["HTTPS_PROXY"] != "http://mine" {
+		t.Errorf("HTTPS_PROXY = %v, want the original", env["HTTPS_PROXY"])
+	}
+
+	applied, err = store.UpdateProfile("first", map[string]string{"FOO": "bar"}, nil)
+	if err != nil || applied {
+		t.Errorf("inactive update = %v, %v", applied, err)
+	}
+	if _, err := store.UpdateProfile("first", nil, []string{EnvModel, "FOO"}); err == nil {
+		t.Error("profile emptied by unset")
+	}
+}
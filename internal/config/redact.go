@@ -0,0 +1,361 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// redactedPrefix starts the placeholder a secret is replaced with in
+// backups. The rest is a short hash of the secret, which is enough to find
+// it again in the profile store on restore without revealing it.
+const redactedPrefix = "ccs-redacted:"
+
+// IsRedacted reports whether value is a backup placeholder for a secret
+func IsRedacted(value string) bool {
+	return strings.HasPrefix(value, redactedPrefix)
+}
+
+// redactedPlaceholder returns the placeholder for secret
+func redactedPlaceholder(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return redactedPrefix + hex.EncodeToString(sum[:8])
+}
+
+// IsSecretKey reports whether an env key always holds a secret
+func IsSecretKey(key string) bool {
+	return key == EnvAuthToken || key == EnvAPIKey
+}
+
+// SecretKeyMatcher returns a test for env keys whose values are secret:
+// the keys MaskValue masks plus the secretPatterns globs in config.json
+func SecretKeyMatcher() func(key string) bool {
+	var patterns []string
+	if cfg, err := LoadConfig(); err == nil {
+		patterns = cfg.SecretPatterns
+	}
+	return func(key string) bool {
+		if IsSecretKey(key) {
+			return true
+		}
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, key); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// redactable reports whether value is a secret that should be replaced;
+// references, placeholders and encrypted values hold nothing to hide
+func redactable(value string) bool {
+	return value != "" && !IsSecretRef(value) && !IsRedacted(value) &&
+		!strings.HasPrefix(value, sealedPrefix) && !isBackupSealed(value)
+}
+
+// redactBackupData replaces the secrets in a backup file of kind with
+// placeholders, reporting whether anything was replaced. In profiles.json
+// they are sealed instead, since after a profile is removed its backup is
+// the only place the token can be restored from.
+func redactBackupData(kind string, data []byte, isSecret func(string) bool) ([]byte, bool, error) {
+	if kind == BackupProfiles {
+		return sealStoreData(data, isSecret)
+	}
+	return mapSecrets(kind, data, isSecret, func(value string) (string, error) {
+		if !redactable(value) {
+			return value, nil
+		}
+		return redactedPlaceholder(value), nil
+	})
+}
+
+// refillBackupData puts the secrets a backup file of kind had redacted or
+// sealed back in, looking placeholders up in index
+func refillBackupData(kind string, data []byte, index *secretIndex) ([]byte, error) {
+	anyKey := func(string) bool { return true }
+	refilled, _, err := mapSecrets(kind, data, anyKey, func(value string) (string, error) {
+		switch {
+		case IsRedacted(value):
+			return index.lookup(value)
+		case isBackupSealed(value):
+			return openBackupValue(value)
+		}
+		return value, nil
+	})
+	return refilled, err
+}
+
+// mapSecrets applies fn to the value of every env key matched by isSecret
+// in a backup file of kind. Data is returned as it was unless fn changed
+// something.
+func mapSecrets(kind string, data []byte, isSecret func(string) bool, fn func(string) (string, error)) ([]byte, bool, error) {
+	changed := false
+	apply := func(key string, value *string) error {
+		if !isSecret(key) {
+			return nil
+		}
+		mapped, err := fn(*value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if mapped != *value {
+			*value = mapped
+			changed = true
+		}
+		return nil
+	}
+	applyEnv := func(env map[string]string) error {
+		for _, key := range sortedKeys(env) {
+			value := env[key]
+			if err := apply(key, &value); err != nil {
+				return err
+			}
+			env[key] = value
+		}
+		return nil
+	}
+
+	var out any
+	switch kind {
+	case BackupSettings, BackupProject, BackupLocal:
+		var settings ClaudeSettings
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return nil, false, err
+		}
+		if err := applyEnv(settings.Env); err != nil {
+			return nil, false, err
+		}
+		out = settings
+
+	case BackupProfiles:
+		var store Store
+		if err := json.Unmarshal(data, &store); err != nil {
+			return nil, false, err
+		}
+		for _, name := range sortedProfileNames(store.Profiles) {
+			if profile := store.Profiles[name]; profile != nil {
+				if err := applyEnv(profile.Env); err != nil {
+					return nil, false, fmt.Errorf("profile '%s': %w", name, err)
+				}
+			}
+		}
+		out = &store
+
+	case BackupOwnership:
+		var ledger Ownership
+		if err := json.Unmarshal(data, &ledger); err != nil {
+			return nil, false, err
+		}
+		for key, entry := range ledger.Env {
+			if err := apply(key, &entry.Original); err != nil {
+				return nil, false, err
+			}
+			if err := apply(key, &entry.Applied); err != nil {
+				return nil, false, err
+			}
+		}
+		out = &ledger
+
+	default:
+		return data, false, nil
+	}
+
+	if !changed {
+		return data, false, nil
+	}
+	mapped, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, false, err
+	}
+	return mapped, true, nil
+}
+
+// sortedProfileNames returns the names of profiles in sorted order
+func sortedProfileNames(profiles map[string]*Profile) []string {
+	names := make(map[string]string, len(profiles))
+	for name := range profiles {
+		names[name] = ""
+	}
+	return sortedKeys(names)
+}
+
+// secretIndex finds the secrets behind backup placeholders. It looks in
+// the live settings.json and ownership ledger and in copies of profiles.json
+// it was given first, then in the profile store and encrypted copies, which
+// are only loaded (and unlocked) if needed.
+type secretIndex struct {
+	values map[string]string // placeholder -> secret
+	stage  int               // how many sources have been indexed
+	// sealed holds encrypted copies of profiles.json
+	sealed []*Store
+}
+
+// newSecretIndex creates an empty index
+func newSecretIndex() *secretIndex {
+	return &secretIndex{values: make(map[string]string)}
+}
+
+// lookup returns the secret behind placeholder
+func (x *secretIndex) lookup(placeholder string) (string, error) {
+	for {
+		if secret, ok := x.values[placeholder]; ok {
+			return secret, nil
+		}
+		more, err := x.indexNext()
+		if err != nil {
+			return "", err
+		}
+		if !more {
+			return "", fmt.Errorf("the redacted secret is no longer in the profile store or settings.json")
+		}
+	}
+}
+
+// indexNext adds the next source of secrets, reporting false once every
+// source has been indexed
+func (x *secretIndex) indexNext() (bool, error) {
+	x.stage++
+	switch x.stage {
+	case 1:
+		if settings, err := GetCurrentClaudeSettings(); err == nil {
+			x.addEnv(settings.Env)
+		}
+		if ledger, err := loadOwnership(); err == nil {
+			for _, entry := range ledger.Env {
+				x.add(entry.Original)
+				x.add(entry.Applied)
+			}
+		}
+	case 2:
+		store, err := loadLiveStore()
+		if err != nil {
+			return false, err
+		}
+		if store != nil {
+			x.addProfiles(store)
+		}
+	case 3:
+		// Unlocking a copy may ask for the passphrase it was encrypted with
+		for _, store := range x.sealed {
+			key, err := store.Encryption.unlockKey()
+			if err != nil {
+				continue
+			}
+			if store.unseal(key) == nil {
+				x.addProfiles(store)
+			}
+		}
+	case 4:
+		store, err := loadLiveStore()
+		if err != nil || store == nil {
+			return err == nil, err
+		}
+		for _, profile := range store.Profiles {
+			// Resolving references can run commands; only do it as a last resort
+			for _, value := range profile.Env {
+				if IsSecretRef(value) {
+					if secret, err := ResolveValue(value); err == nil {
+						x.add(secret)
+					}
+				}
+			}
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// add indexes one secret
+func (x *secretIndex) add(secret string) {
+	if redactable(secret) {
+		x.values[redactedPlaceholder(secret)] = secret
+	}
+}
+
+// loadLiveStore loads the profile store, or returns nil if there is none
+func loadLiveStore() (*Store, error) {
+	if _, err := os.Stat(getProfilesPath()); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return Load()
+}
+
+// addStoreData indexes the values in a copy of profiles.json, opening the
+// ones sealed with the backup key. An encrypted copy is kept to be unlocked
+// only if needed.
+func (x *secretIndex) addStoreData(data []byte) {
+	var store Store
+	if json.Unmarshal(data, &store) != nil {
+		return
+	}
+	if store.Encryption != nil {
+		x.sealed = append(x.sealed, &store)
+		return
+	}
+	for _, profile := range store.Profiles {
+		if profile == nil {
+			continue
+		}
+		for _, value := range profile.Env {
+			if isBackupSealed(value) {
+				var err error
+				if value, err = openBackupValue(value); err != nil {
+					continue
+				}
+			}
+			x.add(value)
+		}
+	}
+}
+
+// addProfiles indexes every value in an unlocked store
+func (x *secretIndex) addProfiles(store *Store) {
+	for _, profile := range store.Profiles {
+		if profile != nil {
+			x.addEnv(profile.Env)
+		}
+	}
+}
+
+// addEnv indexes every value in env
+func (x *secretIndex) addEnv(env map[string]string) {
+	for _, value := range env {
+		x.add(value)
+	}
+}
+
+// ScrubBackups rewrites existing backups and journal entries so they no
+// longer contain secrets, returning the number of files changed
+func ScrubBackups() (int, error) {
+	isSecret := SecretKeyMatcher()
+
+	scrubbed := 0
+	for _, file := range listBackupFiles(getBackupDir()) {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return scrubbed, err
+		}
+
+		redacted, changed, err := redactBackupData(file.kind, data, isSecret)
+		if err != nil {
+			return scrubbed, fmt.Errorf("failed to scrub %s: %w", file.path, err)
+		}
+		if !changed {
+			continue
+		}
+
+		if err := writeFileAtomic(file.path, redacted, privateFileMode); err != nil {
+			return scrubbed, err
+		}
+		scrubbed++
+	}
+
+	journaled, err := scrubJournal(isSecret)
+	return scrubbed + journaled, err
+}
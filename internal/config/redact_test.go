@@ -0,0 +1,160 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupsAreRedactedAndRefilledOnRestore(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_AUTH_TOKEN": "sk-hand-set-token", "ANTHROPIC_MODEL": "before"}}`)
+	store := newSwitchStore(t)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Switch("second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Switch("first"); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := ListBackups()
+	if err != nil || len(backups) == 0 {
+		t.Fatalf("ListBackups: %v %v", backups, err)
+	}
+	for _, backup := range backups {
+		for _, path := range backup.Files {
+			data, _ := os.ReadFile(path)
+			if strings.Contains(string(data), "sk-second") || strings.Contains(string(data), "sk-hand-set-token") {
+				t.Errorf("secret in backup %s: %s", path, data)
+			}
+		}
+	}
+
+	// The newest backup was taken while "second" was applied
+	if err := RestoreBackup(backups[0].ID, BackupSettings); err != nil {
+		t.Fatal(err)
+	}
+	env := readSettingsMap(t)["env"].(map[string]any)
+	if env[EnvAuthToken] != "sk-second" {
+		t.Errorf("token not refilled: %v", env)
+	}
+}
+
+func TestRestoreBringsBackRemovedProfile(t *testing.T) {
+	setupClaudeHome(t, "")
+	store := newSwitchStore(t)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RemoveProfile("second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The profile was never applied, so its token is only in the backup,
+	// sealed with the backup key
+	backups, _ := ListBackups()
+	if data, _ := os.ReadFile(backups[0].Files[BackupProfiles]); strings.Contains(string(data), "sk-second") {
+		t.Errorf("plaintext token in profiles backup: %s", data)
+	}
+	if err := RestoreBackup(backups[0].ID, BackupProfiles); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := restored.GetProfile("second"); err != nil || profile.Env[EnvAuthToken] != "sk-second" {
+		t.Errorf("second not restored with its token: %v %v", profile, err)
+	}
+}
+
+func TestRestoreFailsWhenSecretIsGone(t *testing.T) {
+	home := setupClaudeHome(t, `{"env": {"ANTHROPIC_AUTH_TOKEN": "sk-revoked-token"}}`)
+
+	profile := NewProfile()
+	profile.SetAuthToken("sk-new-token-value")
+	if err := profile.ApplyToClaude(); err != nil {
+		t.Fatal(err)
+	}
+	// Forget the revoked token everywhere but the backup
+	if err := os.Remove(filepath.Join(home, ".ccs", "ownership.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, _ := ListBackups()
+	if err := RestoreBackup(backups[0].ID, BackupSettings); err == nil {
+		t.Fatal("expected restore to fail")
+	}
+}
+
+func TestScrubBackups(t *testing.T) {
+	home := setupClaudeHome(t, "")
+	dir := filepath.Join(home, ".ccs", "backups")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".ccs", "config.json"), []byte(`{"secretPatterns": ["*_SECRET"]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	old := filepath.Join(dir, "settings-20240101-120000.json")
+	if err := os.WriteFile(old, []byte(`{"model": "opus", "env": {"ANTHROPIC_AUTH_TOKEN": "sk-old-token", "MY_SECRET": "hunter2", "ANTHROPIC_MODEL": "m"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	scrubbed, err := ScrubBackups()
+	if err != nil || scrubbed != 1 {
+		t.Fatalf("ScrubBackups = %d, %v", scrubbed, err)
+	}
+	data, _ := os.ReadFile(old)
+	if strings.Contains(string(data), "sk-old-token") || strings.Contains(string(data), "hunter2") {
+		t.Errorf("secret left in scrubbed backup: %s", data)
+	}
+	if !strings.Contains(string(data), `"opus"`) || !strings.Contains(string(data), `"m"`) {
+		t.Errorf("other values lost: %s", data)
+	}
+
+	if scrubbed, _ := ScrubBackups(); scrubbed != 0 {
+		t.Errorf("second scrub changed %d files", scrubbed)
+	}
+}
+
+func TestScrubBackupsSealsProfileStoreCopies(t *testing.T) {
+	home := setupClaudeHome(t, "")
+	dir := filepath.Join(home, ".ccs", "backups")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(dir, "profiles-20240101-120000.json")
+	if err := os.WriteFile(old, []byte(`{"current": "old", "profiles": {"old": {"env": {"ANTHROPIC_AUTH_TOKEN": "sk-old-token", "ANTHROPIC_MODEL": "m"}}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if scrubbed, err := ScrubBackups(); err != nil || scrubbed != 1 {
+		t.Fatalf("ScrubBackups = %d, %v", scrubbed, err)
+	}
+	for _, file := range listBackupFiles(dir) {
+		data, _ := os.ReadFile(file.path)
+		if strings.Contains(string(data), "sk-old-token") {
+			t.Errorf("plaintext token left in %s: %s", file.path, data)
+		}
+	}
+
+	// The sealed token still comes back on restore
+	if err := RestoreBackup("20240101-120000"); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := restored.GetProfile("old"); err != nil || profile.Env[EnvAuthToken] != "sk-old-token" || profile.Env[EnvModel] != "m" {
+		t.Errorf("old not restored: %v %v", profile, err)
+	}
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Scopes a profile can be applied at. User scope is the shared
+// ~/.claude/settings.json; project and local scope are the project's
+// .claude/settings.json and .claude/settings.local.json.
+const (
+	ScopeUser    = "user"
+	ScopeProject = "project"
+	ScopeLocal   = "local"
+)
+
+// Scopes lists the scopes in the order Claude Code gives them precedence,
+// lowest first
+var Scopes = []string{ScopeUser, ScopeProject, ScopeLocal}
+
+// localSettingsName is the project settings file that isn't committed
+const localSettingsName = "settings.local.json"
+
+// ScopeTarget is the settings file a scope resolves to for a directory
+type ScopeTarget struct {
+	Scope string
+	// Root is the project root, empty for user scope
+	Root string
+	// Path is the settings file written at this scope
+	Path string
+}
+
+// ResolveScope returns the settings file scope refers to when working in
+// dir. The project root is the nearest enclosing git work tree, or dir
+// itself outside of git.
+func ResolveScope(scope, dir string) (*ScopeTarget, error) {
+	switch scope {
+	case "", ScopeUser:
+		return &ScopeTarget{Scope: ScopeUser, Path: getClaudeConfigPath()}, nil
+	case ScopeProject, ScopeLocal:
+	default:
+		return nil, fmt.Errorf("unknown scope '%s' (want %s)", scope, strings.Join(Scopes, ", "))
+	}
+
+	root, err := findProjectRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "settings.json"
+	if scope == ScopeLocal {
+		name = localSettingsName
+	}
+	return &ScopeTarget{Scope: scope, Root: root, Path: filepath.Join(root, ".claude", name)}, nil
+}
+
+// findProjectRoot returns the nearest directory at or above dir holding a
+// .git entry, or dir if there is none
+func findProjectRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d, nil
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir, nil
+		}
+		d = parent
+	}
+}
+
+// backupKind returns the backup kind of the target's settings file
+func (t *ScopeTarget) backupKind() string {
+	switch t.Scope {
+	case ScopeProject:
+		return BackupProject
+	case ScopeLocal:
+		return BackupLocal
+	}
+	return BackupSettings
+}
+
+// ScopeCurrent returns the profile applied at target, or "" if none is
+func (s *Store) ScopeCurrent(target *ScopeTarget) string {
+	if target.Scope == ScopeUser {
+		return s.Current
+	}
+	return s.Scoped[target.Path]
+}
+
+// ActiveScopes returns the profile active at each scope for dir, leaving
+// out scopes with no profile applied
+func (s *Store) ActiveScopes(dir string) map[string]string {
+	active := make(map[string]string)
+	for _, scope := range Scopes {
+		target, err := ResolveScope(scope, dir)
+		if err != nil {
+			continue
+		}
+		if name := s.ScopeCurrent(target); name != "" {
+			active[scope] = name
+		}
+	}
+	return active
+}
+
+// SwitchScope makes name the active profile at target. User scope is the
+// same as Switch. At project and local scope the previous profile's values
+// are given back, the new ones applied and the store saved as one
+// transaction, with the ledger kept separately for each settings file.
+//
+// A project settings.json is shared through version control, so neither
+// the token nor an apiKeyHelper naming the profile is written there; the
+// result reports when a token was left out.
+func (s *Store) SwitchScope(name string, target *ScopeTarget) (*SwitchResult, error) {
+	if target.Scope == ScopeUser {
+		return s.Switch(name)
+	}
+
+	profile, err := s.GetProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Isolated {
+		return nil, fmt.Errorf("profile '%s' is isolated and can only be used at user scope", name)
+	}
+
+	mode, err := loadApplyMode()
+	if err != nil {
+		return nil, err
+	}
+	helper := ""
+	if mode == ApplyModeAPIKeyHelper {
+		helper = apiKeyHelperCommand(name)
+	}
+
+	result := &SwitchResult{}
+	applied := profile
+	if target.Scope == ScopeProject {
+		result.TokenOmitted = profile.hasToken()
+		applied, helper = profile.withoutTokens(), ""
+	}
+
+	settings, err := readClaudeSettingsFile(target.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := loadOwnership()
+	if err != nil {
+		return nil, err
+	}
+	ledger := root.scope(target.Path)
+
+	previous := s.ScopeCurrent(target)
+	if previous != "" && previous != name {
+		if oldProfile, err := s.GetProfile(previous); err == nil {
+			if target.Scope == ScopeProject {
+				oldProfile = oldProfile.withoutTokens()
+			}
+			oldProfile.clearFrom(settings, ledger)
+		}
+	}
+
+	if err := applied.applyTo(settings, ledger, helper); err != nil {
+		return nil, err
+	}
+
+	if target.Scope == ScopeLocal {
+		if err := ensureGitIgnored(target.Root, target.Path); err != nil {
+			return nil, fmt.Errorf("failed to git-ignore %s: %w", target.Path, err)
+		}
+	}
+
+	kind := target.backupKind()
+	paths := map[string]string{kind: target.Path}
+	if err := backupFilesAt(paths, kind, BackupOwnership, BackupProfiles); err != nil {
+		return nil, fmt.Errorf("failed to backup current files: %w", err)
+	}
+
+	if s.Scoped == nil {
+		s.Scoped = make(map[string]string)
+	}
+	s.Scoped[target.Path] = name
+
+	// The project's .claude directory is shared, so it isn't made private
+	if err := os.MkdirAll(filepath.Dir(target.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	t := newTxn()
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		err = fmt.Errorf("failed to marshal settings: %w", err)
+	} else {
+		t.stage(target.Path, data, liveFileMode(kind, target.Path))
+		err = root.stage(t)
+	}
+	if err == nil {
+		err = s.stage(t)
+	}
+	if err == nil {
+		err = t.commit()
+	}
+	if err != nil {
+		if previous == "" {
+			delete(s.Scoped, target.Path)
+		} else {
+			s.Scoped[target.Path] = previous
+		}
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// withoutTokens returns a copy of the profile without its token
+func (p *Profile) withoutTokens() *Profile {
+	stripped := NewProfile()
+	for key, value := range p.Env {
+		if !isTokenKey(key) {
+			stripped.Env[key] = value
+		}
+	}
+	return stripped
+}
+
+// ensureGitIgnored makes sure git ignores path inside the work tree at
+// root, adding it to the repository's info/exclude if it isn't ignored
+// yet. Nothing is done outside of git or when git isn't installed.
+func ensureGitIgnored(root, path string) error {
+	if _, err := os.Stat(filepath.Join(root, ".git")); err != nil {
+		return nil
+	}
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return nil
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+
+	// check-ignore exits 0 if ignored and 1 if not
+	check := exec.Command(git, "-C", root, "check-ignore", "-q", "--no-index", rel)
+	if err := check.Run(); err == nil {
+		return nil
+	} else if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+		return fmt.Errorf("git check-ignore failed: %w", err)
+	}
+
+	out, err := exec.Command(git, "-C", root, "rev-parse", "--git-path", "info/exclude").Output()
+	if err != nil {
+		return fmt.Errorf("failed to locate info/exclude: %w", err)
+	}
+	exclude := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(exclude) {
+		exclude = filepath.Join(root, exclude)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(exclude), 0755); err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(exclude)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	line := "/" + rel + "\n"
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		line = "\n" + line
+	}
+	return writeFileAtomic(exclude, append(existing, line...), existingMode(exclude, 0644))
+}
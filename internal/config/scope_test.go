@@ -0,0 +1,132 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readJSONFile(t *testing.T, path string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// newGitRepo returns a new git work tree
+func newGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	return dir
+}
+
+func TestSwitchScopeKeepsScopesApart(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_MODEL": "hand-set"}}`)
+	project := newGitRepo(t)
+	sub := filepath.Join(project, "src")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	store := newSwitchStore(t)
+
+	local, err := ResolveScope(ScopeLocal, sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(project, ".claude", "settings.local.json"); local.Path != want {
+		t.Fatalf("local path = %q, want %q", local.Path, want)
+	}
+	projectScope, err := ResolveScope(ScopeProject, sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.SwitchScope("second", local); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := store.SwitchScope("second", projectScope); err != nil || !result.TokenOmitted {
+		t.Fatalf("SwitchScope = %+v, %v", result, err)
+	}
+
+	env := readJSONFile(t, local.Path)["env"].(map[string]any)
+	if env["ANTHROPIC_MODEL"] != "second-model" || env["ANTHROPIC_AUTH_TOKEN"] != "sk-second" {
+		t.Errorf("local scope not applied: %v", env)
+	}
+	if readSettingsMap(t)["env"].(map[string]any)["ANTHROPIC_MODEL"] != "hand-set" {
+		t.Error("user settings changed by a local switch")
+	}
+
+	// The token stays out of the committed project file
+	shared := readJSONFile(t, projectScope.Path)
+	if _, ok := shared["env"].(map[string]any)["ANTHROPIC_AUTH_TOKEN"]; ok {
+		t.Errorf("token written at project scope: %v", shared)
+	}
+	if _, ok := shared["apiKeyHelper"]; ok {
+		t.Errorf("apiKeyHelper written at project scope: %v", shared)
+	}
+	if shared["env"].(map[string]any)["ANTHROPIC_MODEL"] != "second-model" {
+		t.Errorf("project scope not applied: %v", shared)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	active := loaded.ActiveScopes(sub)
+	if active[ScopeLocal] != "second" || active[ScopeProject] != "second" || active[ScopeUser] != "first" {
+		t.Errorf("active scopes = %v", active)
+	}
+
+	// Switching the local scope gives back only what it applied there
+	if _, err := store.SwitchScope("first", local); err != nil {
+		t.Fatal(err)
+	}
+	env = readJSONFile(t, local.Path)["env"].(map[string]any)
+	if _, ok := env["ANTHROPIC_AUTH_TOKEN"]; ok || env["ANTHROPIC_MODEL"] != "first-model" {
+		t.Errorf("local scope not switched: %v", env)
+	}
+}
+
+func TestSwitchScopeIgnoresLocalSettings(t *testing.T) {
+	setupClaudeHome(t, "")
+	project := newGitRepo(t)
+	store := newSwitchStore(t)
+
+	local, err := ResolveScope(ScopeLocal, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := store.SwitchScope("second", local); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := exec.Command("git", "-C", project, "check-ignore", "-q", ".claude/settings.local.json")
+	if err := check.Run(); err != nil {
+		t.Errorf("settings.local.json is not git-ignored: %v", err)
+	}
+
+	exclude, err := os.ReadFile(filepath.Join(project, ".git", "info", "exclude"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(exclude), "settings.local.json"); n != 1 {
+		t.Errorf("exclude lists settings.local.json %d times", n)
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Secret reference prefixes. A profile value starting with one of these is
+// looked up when the profile is applied instead of being stored literally:
+//
+//	env:VAR             the value of environment variable VAR
+//	file:/path          the contents of a file (~ expands to the home dir)
+//	cmd:<shell command> the output of a command, e.g. "cmd:pass show anthropic"
+const (
+	refEnv  = "env:"
+	refFile = "file:"
+	refCmd  = "cmd:"
+)
+
+// refCheckTimeout bounds each cmd: reference run by CheckRefs
+const refCheckTimeout = 10 * time.Second
+
+// IsSecretRef reports whether value is a secret reference
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, refEnv) ||
+		strings.HasPrefix(value, refFile) ||
+		strings.HasPrefix(value, refCmd)
+}
+
+// ResolveValue returns the value a profile value stands for: the referenced
+// secret for a secret reference, or value itself otherwise. Trailing
+// newlines are trimmed from file contents and command output.
+func ResolveValue(value string) (string, error) {
+	return resolveValue(value, false)
+}
+
+// resolveValue is ResolveValue. When checking, cmd: references run with a
+// timeout and without stdin, so they can't prompt.
+func resolveValue(value string, check bool) (string, error) {
+	switch {
+	case strings.HasPrefix(value, refEnv):
+		name := strings.TrimPrefix(value, refEnv)
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return resolved, nil
+
+	case strings.HasPrefix(value, refFile):
+		path := expandHome(strings.TrimPrefix(value, refFile))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(value, refCmd):
+		return runRefCommand(strings.TrimPrefix(value, refCmd), check)
+	}
+	return value, nil
+}
+
+// runRefCommand runs command through the shell and returns its output.
+// Stdin and stderr are passed through so tools like pass can prompt, unless
+// only checking the reference.
+func runRefCommand(command string, check bool) (string, error) {
+	ctx := context.Background()
+	if check {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, refCheckTimeout)
+		defer cancel()
+	}
+
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	var out bytes.Buffer
+	c.Stdout = &out
+	if !check {
+		c.Stdin = os.Stdin
+		c.Stderr = os.Stderr
+	}
+	if err := c.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("'%s' timed out after %s", command, refCheckTimeout)
+		}
+		return "", fmt.Errorf("'%s' failed: %w", command, err)
+	}
+	return strings.TrimRight(out.String(), "\r\n"), nil
+}
+
+// expandHome replaces a leading ~ in path with the home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, path[1:])
+	}
+	return path
+}
+
+// ResolvedEnv returns the profile's env with every secret reference
+// resolved, or an error naming the first key that fails
+func (p *Profile) ResolvedEnv() (map[string]string, error) {
+	env := make(map[string]string, len(p.Env))
+	for _, key := range sortedKeys(p.Env) {
+		value, err := ResolveValue(p.Env[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", key, err)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// CheckRefs resolves the profile's env and returns the keys whose secret
+// references fail, in order, with their errors. cmd: references are only
+// run if runCommands is set, without stdin and with refCheckTimeout.
+func (p *Profile) CheckRefs(runCommands bool) ([]string, map[string]error) {
+	failed := make(map[string]error)
+	for key, value := range p.Env {
+		if !IsSecretRef(value) || (!runCommands && strings.HasPrefix(value, refCmd)) {
+			continue
+		}
+		if _, err := resolveValue(value, true); err != nil {
+			failed[key] = err
+		}
+	}
+
+	keys := make([]string, 0, len(failed))
+	for key := range failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, failed
+}
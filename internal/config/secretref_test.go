@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecretRefs(t *testing.T) {
+	home := setupClaudeHome(t, "")
+	t.Setenv("CCS_TEST_TOKEN", "sk-from-env")
+	if err := os.WriteFile(filepath.Join(home, "token"), []byte("sk-from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"literal":              "literal",
+		"env:CCS_TEST_TOKEN":   "sk-from-env",
+		"file:~/token":         "sk-from-file",
+		"cmd:echo sk-from-cmd": "sk-from-cmd",
+	}
+	if runtime.GOOS == "windows" {
+		delete(cases, "cmd:echo sk-from-cmd")
+	}
+	for value, want := range cases {
+		got, err := ResolveValue(value)
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+		} else if got != want {
+			t.Errorf("%s resolved to %q, want %q", value, got, want)
+		}
+	}
+
+	if _, err := ResolveValue("env:CCS_TEST_UNSET"); err == nil {
+		t.Error("expected an error for an unset variable")
+	}
+}
+
+func TestApplyResolvesRefsAndKeepsThemInStore(t *testing.T) {
+	setupClaudeHome(t, "")
+	t.Setenv("CCS_TEST_TOKEN", "sk-from-env")
+
+	store := NewStore()
+	profile := NewProfile()
+	profile.SetAuthToken("env:CCS_TEST_TOKEN")
+	if err := store.AddProfile("ref", profile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Switch("ref"); err != nil {
+		t.Fatal(err)
+	}
+
+	env := readSettingsMap(t)["env"].(map[string]any)
+	if env[EnvAuthToken] != "sk-from-env" {
+		t.Errorf("settings.json token = %v, want the resolved value", env[EnvAuthToken])
+	}
+
+	data, err := os.ReadFile(getProfilesPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-from-env") {
+		t.Errorf("resolved secret written to profiles.json: %s", data)
+	}
+
+	if got := MaskValue(EnvAuthToken, "env:CCS_TEST_TOKEN"); got != "env:CCS_TEST_TOKEN" {
+		t.Errorf("reference masked as %q", got)
+	}
+}
+
+func TestApplyFailsOnUnresolvableRef(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_MODEL": "hand-set"}}`)
+
+	store := NewStore()
+	profile := NewProfile()
+	profile.SetModel("ref-model")
+	profile.SetAuthToken("env:CCS_TEST_UNSET")
+	if err := store.AddProfile("ref", profile); err != nil {
+		t.Fatal(err)
+	}
+
+	profile.SetEnv("HELPER_SECRET", "cmd:exit 1")
+	if keys, _ := profile.CheckRefs(false); len(keys) != 1 || keys[0] != EnvAuthToken {
+		t.Errorf("CheckRefs(false) = %v, want [%s]", keys, EnvAuthToken)
+	}
+	if keys, _ := profile.CheckRefs(true); len(keys) != 2 {
+		t.Errorf("CheckRefs(true) = %v, want the cmd: reference too", keys)
+	}
+	if err := profile.ApplyToClaude(); err == nil {
+		t.Fatal("expected apply to fail")
+	}
+	if env := readSettingsMap(t)["env"].(map[string]any); env[EnvModel] != "hand-set" {
+		t.Errorf("settings.json changed by a failed apply: %v", env)
+	}
+}
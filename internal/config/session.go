@@ -0,0 +1,46 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// SessionEnv is set to the profile name in processes started by ccs exec
+// and ccs shell, so prompts and scripts can tell which profile is active
+const SessionEnv = "CCS_PROFILE"
+
+// SessionEnviron returns base (a list of KEY=value strings, as from
+// os.Environ) with env and the session marker for profile name set
+func SessionEnviron(base []string, name string, env map[string]string) []string {
+	set := make(map[string]string, len(env)+1)
+	for key, value := range env {
+		set[key] = value
+	}
+	set[SessionEnv] = name
+
+	environ := make([]string, 0, len(base)+len(set))
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if _, ok := set[key]; !ok {
+			environ = append(environ, kv)
+		}
+	}
+	for _, key := range sortedKeys(set) {
+		environ = append(environ, key+"="+set[key])
+	}
+	return environ
+}
+
+// OverriddenKeys returns the keys of env that settings.json also sets to a
+// different value. Claude Code applies settings.json env on top of its own
+// environment, so those values win over ones injected by ccs exec.
+func OverriddenKeys(env map[string]string, settings *ClaudeSettings) []string {
+	var keys []string
+	for key, value := range env {
+		if current, ok := settings.Env[key]; ok && current != value {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
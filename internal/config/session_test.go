@@ -0,0 +1,22 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSessionEnviron(t *testing.T) {
+	base := []string{"PATH=/bin", "ANTHROPIC_MODEL=outer", "CCS_PROFILE=old"}
+	env := map[string]string{EnvModel: "inner", EnvAuthToken: "sk-x"}
+
+	got := SessionEnviron(base, "work", env)
+	want := []string{"PATH=/bin", "ANTHROPIC_AUTH_TOKEN=sk-x", "ANTHROPIC_MODEL=inner", "CCS_PROFILE=work"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SessionEnviron = %v, want %v", got, want)
+	}
+
+	settings := &ClaudeSettings{Env: map[string]string{EnvModel: "global", EnvAuthToken: "sk-x"}}
+	if keys := OverriddenKeys(env, settings); !reflect.DeepEqual(keys, []string{EnvModel}) {
+		t.Errorf("OverriddenKeys = %v", keys)
+	}
+}
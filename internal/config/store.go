@@ -7,7 +7,6 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
-	"sync"
 )
 
 const (
@@ -15,29 +14,57 @@ const (
 	AppName = "ccs"
 )
 
-var (
-	once         sync.Once
-	profilesPath string
-)
-
 // getProfilesPath returns the path to the profiles.json file
 func getProfilesPath() string {
-	once.Do(func() {
-		home, _ := os.UserHomeDir()
-		profilesPath = filepath.Join(home, ".ccs", "profiles.json")
-	})
-	return profilesPath
+	return filepath.Join(getCCSDir(), "profiles.json")
 }
 
-// getClaudeConfigPath returns the path to Claude's settings.json
-func getClaudeConfigPath() string {
+// getCCSDir returns the ccs state directory (~/.ccs)
+func getCCSDir() string {
 	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".claude", "settings.json")
+	return filepath.Join(home, ".ccs")
+}
+
+// getOwnershipPath returns the path to the env ownership ledger
+func getOwnershipPath() string {
+	return filepath.Join(getCCSDir(), "ownership.json")
 }
 
-// getClaudeJSONPath returns the path to ~/.claude.json
+// ClaudeConfigDirEnv is the variable Claude Code reads its config directory from
+const ClaudeConfigDirEnv = "CLAUDE_CONFIG_DIR"
+
+// userClaudeConfigDir returns $CLAUDE_CONFIG_DIR if the user set it, or ""
+// if it is unset or points at an isolated home set up by ccs
+func userClaudeConfigDir() string {
+	dir := os.Getenv(ClaudeConfigDirEnv)
+	if dir == "" || isIsolatedHome(dir) {
+		return ""
+	}
+	return dir
+}
+
+// getClaudeDir returns the Claude Code config directory shared by profiles
+// that aren't isolated: $CLAUDE_CONFIG_DIR if set, otherwise ~/.claude
+func getClaudeDir() string {
+	if dir := userClaudeConfigDir(); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".claude")
+}
+
+// getClaudeConfigPath returns the path to Claude's shared settings.json
+func getClaudeConfigPath() string {
+	return filepath.Join(getClaudeDir(), "settings.json")
+}
+
+// getClaudeJSONPath returns the path to the shared ~/.claude.json, which
+// Claude Code keeps inside $CLAUDE_CONFIG_DIR when that is set.
 // This file contains MCP servers and hasCompletedOnboarding flag
 func getClaudeJSONPath() string {
+	if dir := userClaudeConfigDir(); dir != "" {
+		return filepath.Join(dir, ".claude.json")
+	}
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, ".claude.json")
 }
@@ -50,6 +77,9 @@ func GetClaudeJSONPath() string {
 // Profile represents a Claude Code configuration profile
 type Profile struct {
 	Env map[string]string `json:"env"`
+	// Isolated profiles get their own Claude Code config directory under
+	// ~/.ccs/homes instead of sharing ~/.claude
+	Isolated bool `json:"isolated,omitempty"`
 }
 
 // NewProfile creates a new profile with the given configuration
@@ -73,7 +103,14 @@ func (p *Profile) GetEnv(key string) (string, bool) {
 // Store represents the profiles storage
 type Store struct {
 	Current string             `json:"current"`
+	// Scoped records the profile applied to each project or local
+	// settings file, keyed by its path
+	Scoped map[string]string `json:"scoped,omitempty"`
 	Profiles map[string]*Profile `json:"profiles"`
+	// Encryption is set when env values are encrypted at rest
+	Encryption *StoreEncryption `json:"encryption,omitempty"`
+
+	key []byte // store key, set once an encrypted store is unlocked
 }
 
 // NewStore creates a new store
@@ -119,35 +156,65 @@ func Load() (*Store, error) {
 		store.Profiles = make(map[string]*Profile)
 	}
 
+	// Decrypt env values if the store is encrypted
+	if store.Encryption != nil {
+		key, err := store.Encryption.unlockKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to unlock profiles: %w", err)
+		}
+		if err := store.unseal(key); err != nil {
+			return nil, err
+		}
+	}
+
 	return &store, nil
 }
 
-// Save saves the profiles to disk
-func (s *Store) Save() error {
-	path := getProfilesPath()
-	dir := filepath.Dir(path)
+// loadCurrentName returns the active profile name from profiles.json
+// without decrypting anything, or "" if it can't be read
+func loadCurrentName() string {
+	data, err := os.ReadFile(getProfilesPath())
+	if err != nil {
+		return ""
+	}
+	var header struct {
+		Current string `json:"current"`
+	}
+	_ = json.Unmarshal(data, &header)
+	return header.Current
+}
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+// Save saves the profiles to disk, backing up the previous profiles.json
+func (s *Store) Save() error {
+	if err := backupProfiles(); err != nil {
+		return fmt.Errorf("failed to backup profiles: %w", err)
 	}
 
-	// Write to temp file first for atomicity
-	tmpPath := path + ".tmp"
-	data, err := json.MarshalIndent(s, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal profiles: %w", err)
+	t := newTxn()
+	if err := s.stage(t); err != nil {
+		return err
 	}
+	return t.commit()
+}
 
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temp file: %w", err)
+// stage queues the profiles to be written to disk by t, encrypting env
+// values if the store is encrypted
+func (s *Store) stage(t *txn) error {
+	onDisk := s
+	if s.Encryption != nil {
+		sealed, err := s.sealed()
+		if err != nil {
+			return fmt.Errorf("failed to encrypt profiles: %w", err)
+		}
+		onDisk = sealed
 	}
 
-	// Atomic rename
-	if err := os.Rename(tmpPath, path); err != nil {
-		return fmt.Errorf("failed to rename temp file: %w", err)
+	data, err := json.MarshalIndent(onDisk, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal profiles: %w", err)
 	}
 
+	t.stage(getProfilesPath(), data, privateFileMode)
 	return nil
 }
 
@@ -178,6 +245,11 @@ func (s *Store) RemoveProfile(name string) error {
 	}
 
 	delete(s.Profiles, name)
+	for path, scoped := range s.Scoped {
+		if scoped == name {
+			delete(s.Scoped, path)
+		}
+	}
 
 	// Update current if we removed the active profile
 	if s.Current == name {
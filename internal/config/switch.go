@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+)
+
+// SwitchResult describes what a profile switch changed besides settings.json
+type SwitchResult struct {
+	// OnboardingSet is true if hasCompletedOnboarding was set in ~/.claude.json
+	OnboardingSet bool
+	// OnboardingErr is set if ~/.claude.json could not be read, in which case
+	// the switch went ahead without touching it
+	OnboardingErr error
+	// ConfigDir is the isolated home Claude Code must be started with
+	// (as CLAUDE_CONFIG_DIR) if the profile is isolated
+	ConfigDir string
+	// LinkErr is set if some shared items could not be linked into the
+	// isolated home
+	LinkErr error
+	// TokenOmitted is set when the profile's token was left out of a
+	// project settings file
+	TokenOmitted bool
+}
+
+// Switch makes name the active profile. Clearing the old profile, applying
+// the new one, setting the onboarding flag and saving the store are staged
+// and committed as one transaction: if any write fails, settings.json,
+// ~/.claude.json, the ownership ledger and profiles.json are all rolled
+// back to their pre-switch state and s is left unchanged.
+//
+// An isolated profile is written to its own home under ~/.ccs/homes; the
+// shared settings.json only loses the previous profile's values.
+func (s *Store) Switch(name string) (*SwitchResult, error) {
+	profile, err := s.GetProfile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := loadApplyMode()
+	if err != nil {
+		return nil, err
+	}
+	helper := ""
+	if mode == ApplyModeAPIKeyHelper {
+		helper = apiKeyHelperCommand(name)
+	}
+
+	claudePath := getClaudeConfigPath()
+	settings, err := readClaudeSettingsFile(claudePath)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := loadOwnership()
+	if err != nil {
+		return nil, err
+	}
+
+	// If there was a previous active profile, restore what it overwrote first
+	if s.Current != "" && s.Current != name {
+		if oldProfile, err := s.GetProfile(s.Current); err == nil && !oldProfile.Isolated {
+			oldProfile.clearFrom(settings, ledger)
+		}
+	}
+
+	result := &SwitchResult{}
+	t := newTxn()
+
+	var claudeJSON *jsonObject
+	if profile.Isolated {
+		result.ConfigDir, result.LinkErr = prepareIsolatedHome(name)
+		if result.ConfigDir == "" {
+			return nil, result.LinkErr
+		}
+		if err := profile.stageIsolated(t, result.ConfigDir, helper, ledger); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := profile.applyTo(settings, ledger, helper); err != nil {
+			return nil, err
+		}
+
+		// Set hasCompletedOnboarding to skip Claude Code's first-time setup
+		claudeJSON, err = readClaudeJSON()
+		if err != nil {
+			result.OnboardingErr = err
+		} else {
+			result.OnboardingSet, result.OnboardingErr = setOnboardingFlag(claudeJSON)
+		}
+	}
+
+	// Backup every file the switch will write, under one backup id
+	kinds := []string{BackupSettings, BackupOwnership, BackupProfiles}
+	if result.OnboardingSet {
+		kinds = append(kinds, BackupClaude)
+	}
+	if err := backupFiles(kinds...); err != nil {
+		return nil, fmt.Errorf("failed to backup current files: %w", err)
+	}
+
+	previous := s.Current
+	s.Current = name
+
+	err = stageClaudeSettings(t, claudePath, settings)
+	if err == nil {
+		err = ledger.stage(t)
+	}
+	if err == nil && result.OnboardingSet {
+		err = stageClaudeJSON(t, claudeJSON)
+	}
+	if err == nil {
+		err = s.stage(t)
+	}
+	if err == nil {
+		err = t.commit()
+	}
+	if err != nil {
+		s.Current = previous
+		return nil, err
+	}
+
+	return result, nil
+}
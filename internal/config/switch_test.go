@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func newSwitchStore(t *testing.T) *Store {
+	t.Helper()
+	store := NewStore()
+
+	first := NewProfile()
+	first.SetModel("first-model")
+	second := NewProfile()
+	second.SetModel("second-model")
+	second.SetAuthToken("sk-second")
+
+	if err := store.AddProfile("first", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddProfile("second", second); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestSwitchCommitsAllFiles(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_MODEL": "hand-set"}}`)
+	store := newSwitchStore(t)
+
+	if _, err := store.Switch("first"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := store.Switch("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OnboardingErr != nil {
+		t.Errorf("unexpected onboarding error: %v", result.OnboardingErr)
+	}
+
+	env := readSettingsMap(t)["env"].(map[string]any)
+	if env["ANTHROPIC_MODEL"] != "second-model" || env["ANTHROPIC_AUTH_TOKEN"] != "sk-second" {
+		t.Errorf("second profile not applied: %v", env)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Current != "second" {
+		t.Errorf("profiles.json current = %q, want second", loaded.Current)
+	}
+
+	done, err := IsHasCompletedOnboarding()
+	if err != nil || !done {
+		t.Errorf("onboarding flag not set: %v %v", done, err)
+	}
+}
+
+func TestSwitchRollsBackOnFailure(t *testing.T) {
+	setupClaudeHome(t, `{"env": {"ANTHROPIC_MODEL": "hand-set"}}`)
+	store := newSwitchStore(t)
+
+	if _, err := store.Switch("first"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(getClaudeConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledgerBefore, err := os.ReadFile(getOwnershipPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make profiles.json impossible to replace so the last write fails
+	if err := os.Remove(getProfilesPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(getProfilesPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(getProfilesPath()+"/keep", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Switch("second"); err == nil {
+		t.Fatal("expected switch to fail")
+	}
+
+	if store.Current != "first" {
+		t.Errorf("store.Current = %q after failed switch, want first", store.Current)
+	}
+	after, _ := os.ReadFile(getClaudeConfigPath())
+	if string(after) != string(before) {
+		t.Errorf("settings.json not rolled back:\ngot  %s\nwant %s", after, before)
+	}
+	ledgerAfter, _ := os.ReadFile(getOwnershipPath())
+	if string(ledgerAfter) != string(ledgerBefore) {
+		t.Errorf("ownership ledger not rolled back:\ngot  %s\nwant %s", ledgerAfter, ledgerBefore)
+	}
+}
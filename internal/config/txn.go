@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// stagedWrite is a file write waiting for a transaction to commit
+type stagedWrite struct {
+	path string
+	data []byte
+	perm os.FileMode
+}
+
+// fileSnapshot is the content of a file before a transaction touched it
+type fileSnapshot struct {
+	path    string
+	data    []byte
+	perm    os.FileMode
+	existed bool
+}
+
+// txn stages writes to several files and commits them together. If any
+// write fails, files already written are put back the way they were.
+type txn struct {
+	writes []stagedWrite
+}
+
+// newTxn creates an empty transaction
+func newTxn() *txn {
+	return &txn{}
+}
+
+// stage queues data to be written to path on commit
+func (t *txn) stage(path string, data []byte, perm os.FileMode) {
+	t.writes = append(t.writes, stagedWrite{path: path, data: data, perm: perm})
+}
+
+// commit snapshots every staged file, then writes them in order, rolling
+// back to the snapshots on the first failure
+func (t *txn) commit() error {
+	snapshots := make([]fileSnapshot, 0, len(t.writes))
+	for _, w := range t.writes {
+		snap, err := takeSnapshot(w.path)
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, snap)
+	}
+
+	for i, w := range t.writes {
+		err := os.MkdirAll(filepath.Dir(w.path), privateDirMode)
+		if err == nil {
+			err = writeFileAtomic(w.path, w.data, w.perm)
+		}
+		if err != nil {
+			if rbErr := rollback(snapshots[:i]); rbErr != nil {
+				return errors.Join(fmt.Errorf("failed to write %s: %w", w.path, err), rbErr)
+			}
+			return fmt.Errorf("failed to write %s (changes rolled back): %w", w.path, err)
+		}
+	}
+
+	return nil
+}
+
+// takeSnapshot records the current content of path
+func takeSnapshot(path string) (fileSnapshot, error) {
+	snap := fileSnapshot{path: path, perm: privateFileMode}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return snap, nil
+		}
+		return snap, fmt.Errorf("failed to snapshot %s: %w", path, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snap, fmt.Errorf("failed to snapshot %s: %w", path, err)
+	}
+
+	snap.data = data
+	snap.perm = info.Mode().Perm()
+	snap.existed = true
+	return snap, nil
+}
+
+// rollback restores snapshots in reverse order
+func rollback(snapshots []fileSnapshot) error {
+	var errs []error
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snap := snapshots[i]
+		if !snap.existed {
+			if err := os.Remove(snap.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("failed to roll back %s: %w", snap.path, err))
+			}
+			continue
+		}
+		if err := writeFileAtomic(snap.path, snap.data, snap.perm); err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll back %s: %w", snap.path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// modelKeys are the profile keys that name a model
+var modelKeys = []string{
+	EnvDefaultHaikuModel,
+	EnvDefaultOpusModel,
+	EnvDefaultSonnetModel,
+	EnvModel,
+}
+
+// IsModelKey reports whether key names a model
+func IsModelKey(key string) bool {
+	for _, k := range modelKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateEnvValue checks value for key and returns it normalized. Base
+// URLs must be http or https URLs with a host, and tokens and model names
+// can't contain spaces. Secret references and other keys are accepted as
+// they are.
+func ValidateEnvValue(key, value string) (string, error) {
+	if IsSecretRef(value) {
+		return value, nil
+	}
+	switch {
+	case key == EnvBaseURL:
+		return ValidateBaseURL(value)
+	case IsSecretKey(key), IsModelKey(key):
+		if i := strings.IndexFunc(value, unicode.IsSpace); i >= 0 {
+			return "", fmt.Errorf("must not contain spaces")
+		}
+		if i := strings.IndexFunc(value, unicode.IsControl); i >= 0 {
+			return "", fmt.Errorf("must not contain control characters")
+		}
+	}
+	return value, nil
+}
+
+// ValidateBaseURL checks an API base URL and returns it without a trailing
+// slash, since Claude Code appends the API path itself
+func ValidateBaseURL(value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("not a valid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("must start with https:// (or http://)")
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return "", fmt.Errorf("missing host")
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("must not contain credentials; use ANTHROPIC_AUTH_TOKEN")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("must not contain a query or fragment")
+	}
+	return strings.TrimRight(value, "/"), nil
+}
+
+// IsInsecureURL reports whether a base URL would send the token
+// unencrypted to another machine
+func IsInsecureURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip == nil || !ip.IsLoopback()
+}
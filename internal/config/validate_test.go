@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestValidateEnvValue(t *testing.T) {
+	tests := []struct {
+		key, value string
+		want       string
+		wantErr    bool
+	}{
+		{EnvBaseURL, "https://api.example.com/", "https://api.example.com", false},
+		{EnvBaseURL, "http://localhost:8080/anthropic", "http://localhost:8080/anthropic", false},
+		{EnvBaseURL, "api.example.com", "", true},
+		{EnvBaseURL, "ftp://api.example.com", "", true},
+		{EnvBaseURL, "https://", "", true},
+		{EnvBaseURL, "https://user:pw@api.example.com", "", true},
+		{EnvBaseURL, "https://api.example.com/?x=1", "", true},
+		{EnvBaseURL, "env:BASE_URL", "env:BASE_URL", false},
+		{EnvAuthToken, "sk-abc", "sk-abc", false},
+		{EnvAuthToken, "sk-abc def", "", true},
+		{EnvAuthToken, "cmd:pass show anthropic", "cmd:pass show anthropic", false},
+		{EnvModel, "glm-4.7", "glm-4.7", false},
+		{EnvModel, "glm 4.7", "", true},
+		{"HTTPS_PROXY", "anything goes", "anything goes", false},
+	}
+
+	for _, tt := range tests {
+		got, err := ValidateEnvValue(tt.key, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateEnvValue(%s, %q) error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ValidateEnvValue(%s, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIsInsecureURL(t *testing.T) {
+	for url, want := range map[string]bool{
+		"https://api.example.com":  false,
+		"http://localhost:8080":    false,
+		"http://127.0.0.1:3000":    false,
+		"http://[::1]:3000":        false,
+		"http://gateway.internal":  true,
+		"http://10.0.0.5:8080/api": true,
+	} {
+		if got := IsInsecureURL(url); got != want {
+			t.Errorf("IsInsecureURL(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
@@ -8,11 +8,15 @@ import (
 
 // listItem represents a profile in the list
 type listItem struct {
-	name   string
-	active bool
+	name    string
+	active  bool
+	drifted bool
 }
 
 func (i listItem) Title() string {
+	if i.active && i.drifted {
+		return i.name + " (active, drifted)"
+	}
 	if i.active {
 		return i.name + " (active)"
 	}
@@ -70,13 +74,15 @@ func NewListPanel() *ListPanel {
 	}
 }
 
-// SetItems sets the items in the list
-func (p *ListPanel) SetItems(names []string, current string) {
+// SetItems sets the items in the list, marking current as drifted if its
+// settings no longer match it
+func (p *ListPanel) SetItems(names []string, current string, drifted bool) {
 	items := make([]list.Item, len(names))
 	for i, name := range names {
 		items[i] = listItem{
-			name:   name,
-			active: name == current,
+			name:    name,
+			active:  name == current,
+			drifted: name == current && drifted,
 		}
 	}
 	p.list.SetItems(items)
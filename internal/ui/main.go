@@ -50,26 +50,24 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Switch to selected profile
 			selected := m.listPanel.GetSelected()
 			if selected != "" {
-				profile, err := m.store.GetProfile(selected)
-				if err == nil {
-					// Clear old profile if different
-					if m.store.Current != "" && m.store.Current != selected {
-						if oldProfile, err := m.store.GetProfile(m.store.Current); err == nil {
-							_ = oldProfile.ClearFromClaude()
-						}
-					}
-
-					// Apply new profile
-					if err := profile.ApplyToClaude(); err != nil {
-						return m, nil // Error handled silently in TUI
+				// Reload under the lock so changes made by other ccs
+				// processes aren't overwritten, then switch as one transaction
+				err := config.WithLock(func() error {
+					if err := m.reloadStore(); err != nil {
+						return err
 					}
-
-					// Update store
-					m.store.SetCurrent(selected)
-					_ = m.store.Save()
-
-					// Refresh list to show new active
-					m.listPanel.SetItems(m.store.GetProfileNames(), selected)
+					return config.Record("use "+selected, func() error {
+						_, err := m.store.Switch(selected)
+						return err
+					})
+				})
+
+				// Refresh list to show new active
+				m.listPanel.SetItems(m.store.GetProfileNames(), m.store.Current, isDrifted(m.store))
+				if err != nil {
+					return m, nil // Error handled silently in TUI
+				}
+				if profile, err := m.store.GetProfile(selected); err == nil {
 					m.preview.SetProfile(profile)
 				}
 			}
@@ -78,18 +76,28 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Remove selected profile
 			selected := m.listPanel.GetSelected()
 			if selected != "" {
-				if m.store.Current == selected {
-					if profile, err := m.store.GetProfile(selected); err == nil {
-						_ = profile.ClearFromClaude()
+				_ = config.WithLock(func() error {
+					if err := m.reloadStore(); err != nil {
+						return err
 					}
-				}
-				_ = m.store.RemoveProfile(selected)
-				_ = m.store.Save()
+					// Journaled so a removal without confirmation can be undone
+					return config.Record("rm "+selected, func() error {
+						if m.store.Current == selected {
+							if profile, err := m.store.GetProfile(selected); err == nil {
+								_ = profile.ClearFromClaude()
+							}
+						}
+						if err := m.store.RemoveProfile(selected); err != nil {
+							return err
+						}
+						return m.store.Save()
+					})
+				})
 
 				// Refresh list
 				profiles := m.store.GetProfileNames()
 				current := m.store.Current
-				m.listPanel.SetItems(profiles, current)
+				m.listPanel.SetItems(profiles, current, isDrifted(m.store))
 				if current != "" {
 					if profile, err := m.store.GetProfile(current); err == nil {
 						m.preview.SetProfile(profile)
@@ -127,6 +135,24 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// reloadStore replaces the in-memory store with the one on disk; call it
+// while holding the state lock
+func (m *Model) reloadStore() error {
+	store, err := config.Load()
+	if err != nil {
+		return err
+	}
+	m.store = store
+	return nil
+}
+
+// isDrifted reports whether the active profile's settings were changed
+// since it was applied
+func isDrifted(store *config.Store) bool {
+	report, err := store.CheckDrift()
+	return err == nil && report != nil && report.Drifted()
+}
+
 // resize handles window resize
 func (m *Model) resize() {
 	listWidth := m.width / 2
@@ -179,7 +205,7 @@ func NewModel() (*Model, error) {
 	current := store.Current
 
 	listPanel := NewListPanel()
-	listPanel.SetItems(profiles, current)
+	listPanel.SetItems(profiles, current, isDrifted(store))
 
 	previewPanel := NewPreviewPanel()
 	if current != "" {
@@ -2,6 +2,7 @@ package ui
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/bytedance/ccs/internal/config"
 	"github.com/charmbracelet/lipgloss"
@@ -57,7 +58,7 @@ func (p *PreviewPanel) View() string {
 
 	lines := []string{previewStyles.title.Render("Profile Details")}
 
-	// Display all env vars in order
+	// Display the standard env vars in order, then any others by name
 	for _, key := range config.EnvKeys {
 		value, exists := p.profile.GetEnv(key)
 		if exists {
@@ -70,6 +71,12 @@ func (p *PreviewPanel) View() string {
 			}
 		}
 	}
+	for _, key := range customKeys(p.profile) {
+		lines = append(lines, fmt.Sprintf("%s%s",
+			previewStyles.key.Render(key),
+			previewStyles.value.Render(maskValue(key, p.profile.Env[key])),
+		))
+	}
 
 	return lipgloss.NewStyle().Width(p.width).Height(p.height).Render(
 		lipgloss.JoinVertical(lipgloss.Left, lines...),
@@ -83,14 +90,19 @@ func (p *PreviewPanel) renderEmpty() string {
 	).Render(previewStyles.empty.Render("No profile selected"))
 }
 
-// maskValue masks sensitive values for display
-func maskValue(key, value string) string {
-	// Mask API tokens
-	if key == config.EnvAuthToken {
-		if len(value) <= 8 {
-			return "***"
+// customKeys returns the profile's env keys that have no label, sorted
+func customKeys(profile *config.Profile) []string {
+	var keys []string
+	for key := range profile.Env {
+		if _, ok := config.EnvLabels[key]; !ok {
+			keys = append(keys, key)
 		}
-		return value[:4] + "***" + value[len(value)-4:]
 	}
-	return value
+	sort.Strings(keys)
+	return keys
+}
+
+// maskValue masks sensitive values for display
+func maskValue(key, value string) string {
+	return config.MaskValue(key, value)
 }